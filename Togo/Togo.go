@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +15,22 @@ import (
 
 const DATABASE_NAME string = "./togos.db"
 
+const TIMEZONE string = "Asia/Tehran"
+
+// localTimezone is loaded once; loading it for every togo read from the database is slow
+var localTimezone = loadTimezone()
+
+const CREATE_TABLE_QUERY string = `CREATE TABLE IF NOT EXISTS togos (id INTEGER PRIMARY KEY AUTOINCREMENT, owner_id BIGINT NOT NULL,
+	title VARCHAR(64) NOT NULL, description VARCHAR(1024), weight INTEGER, extra INTEGER,
+	progress INTEGER, date DATETIME, duration INTEGER)`
+
+const CREATE_TOGOS_INDEXES_QUERY string = `CREATE INDEX IF NOT EXISTS togos_owner_date ON togos (owner_id, date);
+	CREATE INDEX IF NOT EXISTS togos_datetime ON togos (datetime(date))`
+
+const INSERT_TOGO_QUERY string = "INSERT INTO togos (owner_id, title, description, weight, extra, progress, date, duration, assignee) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+const SELECT_TOGOS_QUERY string = "SELECT id, owner_id, title, description, weight, extra, progress, date, duration, rollovers, assignee, skipped FROM togos"
+
 // var taskScheduler chrono.TaskScheduler = chrono.NewDefaultTaskScheduler()
 
 // ---------------------- Date/Time Struct & Date Receivers --------------------------------
@@ -37,11 +54,15 @@ func (d *Date) Short() string {
 // 	return time.Now()
 // }
 
-func (date Date) ToLocal() Date {
-	if timezone, err := time.LoadLocation("Asia/Tehran"); err == nil {
-		return Date{date.In(timezone)}
+func loadTimezone() *time.Location {
+	if timezone, err := time.LoadLocation(TIMEZONE); err == nil {
+		return timezone
 	}
-	return date
+	return time.Local
+}
+
+func (date Date) ToLocal() Date {
+	return Date{date.In(localTimezone)}
 }
 
 func Now() Date {
@@ -62,37 +83,42 @@ type Togo struct {
 	Extra       bool
 	Date        Date
 	Duration    time.Duration
-	OwnerId     int64 // telegram id
+	OwnerId     int64  // telegram id
+	Rollovers   uint16 // number of times it's moved to the next day unfinished
+	Assignee    string // username of the group member it's assigned to, without @
+	Skipped     bool   // dismissed from its reminder; not counted as open, nor reminded or rolled over
 }
 
 func (togo *Togo) Save() (uint64, error) {
-	const CREATE_TABLE_QUERY string = `CREATE TABLE IF NOT EXISTS togos (id INTEGER PRIMARY KEY AUTOINCREMENT, owner_id BIGINT NOT NULL,
-	title VARCHAR(64) NOT NULL, description VARCHAR(1024), weight INTEGER, extra INTEGER,
-	progress INTEGER, date DATETIME, duration INTEGER)`
-
-	db, err := sql.Open("sqlite3", DATABASE_NAME)
-
+	insert, err := statement(INSERT_TOGO_QUERY)
 	if err != nil {
 		return 0, err
 	}
-	defer db.Close()
-	if _, err := db.Exec(CREATE_TABLE_QUERY); err != nil {
-		return 0, err
-	}
 	extra := 0
 	if togo.Extra {
 		extra = 1
 	}
-	if res, err := db.Exec("INSERT INTO togos (owner_id, title, description, weight, extra, progress, date, duration) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		togo.OwnerId, togo.Title, togo.Description, togo.Weight, extra, togo.Progress,
-		togo.Date.Time, togo.Duration.Minutes()); err != nil {
+	if res, err := insert.Exec(togo.OwnerId, togo.Title, togo.Description, togo.Weight, extra, togo.Progress,
+		togo.Date.Time, togo.Duration.Minutes(), togo.Assignee); err != nil {
 		return 0, err
 	} else if id, e := res.LastInsertId(); e == nil {
+		countCreatedTogos(togo.OwnerId, 1) // just used for statistics, so errors don't matter
+		saved := *togo
+		saved.Id = uint64(id)
+		notifyChange(&saved, false)
 		return uint64(id), nil
 	}
 	return 0, errors.New("bot couldn't save this togo due to unknown reason")
 }
 
+// Assignee reads the username of an assignee like @username; - means nobody
+func Assignee(mention string) string {
+	if mention = strings.TrimPrefix(strings.TrimSpace(mention), "@"); mention == "-" {
+		return ""
+	}
+	return mention
+}
+
 func isCommand(term string) bool {
 	return term == "+" || term == "%" || term == "#" || term == "$"
 }
@@ -111,6 +137,9 @@ func (togo *Togo) setFields(terms []string) error {
 		case ":", "+d":
 			i++
 			togo.Description = terms[i]
+		case "&":
+			i++
+			togo.Assignee = Assignee(terms[i])
 		case "+x":
 			togo.Extra = true
 		case "-x":
@@ -127,31 +156,33 @@ func (togo *Togo) setFields(terms []string) error {
 			// im++
 			i++
 			today := Today()
-			var delta int
-			if _, err := fmt.Sscan(terms[i], &delta); err != nil {
+			// the day is either days from today, or an absolute date like 1403/07/28 (jalali) or 2024-10-19
+			if delta, err := strconv.Atoi(terms[i]); err == nil {
+				today = Date{today.AddDate(0, 0, delta)}
+			} else if day, err := ParseDay(terms[i], today.Location()); err == nil {
+				today = Date{day}
+			} else {
 				return err
 			}
-			today = Date{today.AddDate(0, 0, delta)}
 			i++
 			temp := strings.Split(terms[i], ":")
-			var hour, min int
-			if _, err := fmt.Sscan(temp[0], &hour); err != nil {
+			if len(temp) != 2 {
+				return errors.New("time must be like hh:mm")
+			}
+			// Atoi, since Sscan takes 08 and 09 as invalid octal numbers
+			hour, err := strconv.Atoi(temp[0])
+			if err != nil {
 				return err
 			} else if hour >= 24 || hour < 0 {
 				return errors.New("hour part must be between 0 and 23")
 			}
-			if _, err := fmt.Sscan(temp[1], &min); err != nil {
+			min, err := strconv.Atoi(temp[1])
+			if err != nil {
 				return err
 			} else if min >= 60 || min < 0 {
 				return errors.New("minute part must be between 0 and 59")
 			}
-			if locale, err := time.LoadLocation("Asia/Tehran"); err == nil {
-
-				togo.Date = Date{time.Date(today.Year(), today.Month(), today.Day(), hour, min, 0, 0, locale)}
-			} else {
-				togo.Date = Date{time.Date(today.Year(), today.Month(), today.Day(), hour, min, 0, 0, time.Local)}
-
-			}
+			togo.Date = Date{time.Date(today.Year(), today.Month(), today.Day(), hour, min, 0, 0, localTimezone)}
 			// get the actual date here
 		case "->":
 			i++
@@ -169,36 +200,55 @@ func (togo *Togo) setFields(terms []string) error {
 }
 
 func (togo *Togo) Update(ownerID int64) error {
-	db, err := sql.Open("sqlite3", DATABASE_NAME)
-
+	update, err := statement("UPDATE togos SET description=?, weight=?, extra=?, progress=?, date=?, duration=?, assignee=?, skipped=? WHERE id=? AND owner_id=?") // TODO: check ownerId? (no need)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
 	extra := 0
 	if togo.Extra {
 		extra = 1
 	}
-	if _, err := db.Exec("UPDATE togos SET description=?, weight=?, extra=?, progress=?, date=?, duration=? WHERE id=? AND owner_id=?", // TODO: check ownerId? (no need)
-		togo.Description, togo.Weight, extra, togo.Progress, togo.Date.Time, togo.Duration.Minutes(), togo.Id, ownerID); err != nil {
+	if result, err := update.Exec(togo.Description, togo.Weight, extra, togo.Progress, togo.Date.Time, togo.Duration.Minutes(), togo.Assignee, togo.Skipped, togo.Id, ownerID); err != nil {
 		return err
+	} else if count, _ := result.RowsAffected(); count > 0 {
+		notifyChange(togo, false)
 	}
 	return nil
 }
 
 func (togo *Togo) ToString() string {
-	return fmt.Sprintf("Togo #%d) %s:\t%s\nWeight: %d\nExtra: %t\nProgress: %d\nAt: %s, about %.1f minutes",
-		togo.Id, togo.Title, togo.Description, togo.Weight, togo.Extra, togo.Progress, togo.Date.Get(), togo.Duration.Minutes())
+	return togo.Localize(DefaultLocale)
+}
+
+// Localize is ToString in the language and calendar of the locale; each line is aligned right in right-to-left languages
+func (togo *Togo) Localize(locale Locale) string {
+	lines := []string{locale.Text("togo", togo.Id, togo.Title, togo.Description), locale.Text("weight", togo.Weight),
+		locale.Text("extra", locale.Text(fmt.Sprint(togo.Extra))), locale.Text("progress", togo.Progress),
+		locale.Text("at", locale.FormatDate(&togo.Date), togo.Duration.Minutes())}
+	if togo.Assignee != "" {
+		lines = append(lines, locale.Text("assigned_to", togo.Assignee))
+	}
+	if togo.Rollovers > 0 {
+		lines = append(lines, locale.Text("rollovers", togo.Rollovers))
+	}
+	if togo.Skipped {
+		lines = append(lines, locale.Text("skip_mark"))
+	}
+	return locale.Align(strings.Join(lines, "\n"))
 }
 
 // ---------------------- TogoList Type & Togo Receivers--------------------------------
 type TogoList []Togo
 
-func (togos TogoList) ToString() (result []string) {
+func (togos TogoList) ToString() []string {
+	return togos.Localize(DefaultLocale)
+}
+
+func (togos TogoList) Localize(locale Locale) (result []string) {
 	//result = "- - - - - - - - - - - - - - - - - - - - - -"
 	for i := range togos {
-		result = append(result, togos[i].ToString())
+		result = append(result, togos[i].Localize(locale))
 	}
 	return
 }
@@ -210,6 +260,9 @@ func (togos TogoList) Add(newTogo *Togo) TogoList {
 func (togos TogoList) ProgressMade() (progress float64, completedInPercent float64, completed uint64, extra uint64, total uint64) {
 	totalInPercent := uint64(0)
 	for i := range togos {
+		if togos[i].Skipped {
+			continue
+		}
 		progress += float64(togos[i].Progress) * float64(togos[i].Weight)
 		if togos[i].Progress == 100 {
 			completed++
@@ -229,7 +282,7 @@ func (togos TogoList) ProgressMade() (progress float64, completedInPercent float
 	return
 }
 
-func (togos TogoList) Update(chatID int64, terms []string) (string, error) {
+func (togos TogoList) Update(chatID int64, terms []string, locale Locale) (string, error) {
 	var id uint64
 	if _, err := fmt.Sscan(terms[0], &id); err != nil {
 		return "", err
@@ -251,7 +304,7 @@ func (togos TogoList) Update(chatID int64, terms []string) (string, error) {
 		togos[targetIdx].Update(chatID)
 	}
 
-	return togos[targetIdx].ToString(), nil
+	return togos[targetIdx].Localize(locale), nil
 }
 
 func (togos TogoList) RemoveIndex(index int) TogoList {
@@ -262,19 +315,19 @@ func (togos TogoList) RemoveIndex(index int) TogoList {
 	if count == 1 {
 		return make(TogoList, 0)
 	}
-	return append(togos[:index])
+	return togos[:index]
 }
 
 func (togos TogoList) Remove(ownerID int64, togoID uint64) (TogoList, error) {
-	db, err := sql.Open("sqlite3", DATABASE_NAME)
-
+	remove, err := statement("DELETE FROM togos WHERE id=? AND owner_id=?")
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	if _, err := db.Exec("DELETE FROM togos WHERE id=? AND owner_id=?", togoID, ownerID); err != nil {
+	if result, err := remove.Exec(togoID, ownerID); err != nil {
 		return nil, err
+	} else if count, _ := result.RowsAffected(); count > 0 {
+		notifyChange(&Togo{Id: togoID, OwnerId: ownerID}, true)
 	}
 	for i := range togos {
 		if togos[i].Id == togoID && togos[i].OwnerId == ownerID {
@@ -294,48 +347,64 @@ func (togos TogoList) Get(togoID uint64) (*Togo, error) {
 	return nil, errors.New("can not find this togo")
 }
 
+// ---------------------- Change Listener --------------------------------
+// ChangeListener is called after a togo is saved, updated or removed (removed togos only have Id and OwnerId)
+type ChangeListener func(togo *Togo, removed bool)
+
+var changeListener ChangeListener
+
+// OnChange sets the listener that is told about every change in togos, like the reminder scheduler
+func OnChange(listener ChangeListener) {
+	changeListener = listener
+}
+
+func notifyChange(togo *Togo, removed bool) {
+	if changeListener != nil {
+		changeListener(togo, removed)
+	}
+}
+
 // ---------------------- Shared Functions --------------------------------
-func Load(ownerId int64, justToday bool) (togos TogoList, err error) {
+// scanTogo reads a togo from a row of SELECT_TOGOS_QUERY
+func scanTogo(rows *sql.Rows) (togo Togo, err error) {
+	var date time.Time
+	if err = rows.Scan(&togo.Id, &togo.OwnerId, &togo.Title, &togo.Description, &togo.Weight, &togo.Extra, &togo.Progress,
+		&date, &togo.Duration, &togo.Rollovers, &togo.Assignee, &togo.Skipped); err != nil {
+		return
+	}
+	togo.Date = Date{date}.ToLocal()
+	togo.Duration *= time.Minute
+	return
+}
+
+// Load loads the togos of the owner in the range, sorted by date
+func Load(ownerId int64, within Range) (togos TogoList, err error) {
 	currupted_rows := 0
 	togos = make(TogoList, 0)
 	err = nil
-	if db, e := sql.Open("sqlite3", DATABASE_NAME); e == nil {
-		defer db.Close()
+	query, args := SELECT_TOGOS_QUERY+" WHERE owner_id=? ORDER BY date", []interface{}{ownerId}
+	if !within.IsAll() {
+		// dates are stored as text with their time zone, so they're compared in UTC by datetime()
+		query = SELECT_TOGOS_QUERY + " WHERE owner_id=? AND datetime(date) >= datetime(?) AND datetime(date) < datetime(?) ORDER BY date"
+		args = append(args, within.From.Time, within.To.Time)
+	}
+	if load, e := statement(query); e == nil {
 		// ***** BETTER ALGORITHM
 		// FIRST GET THE COUNT OF ROWS, then create a slice of that size and then load into that.
-		const SELECT_QUERY string = "SELECT id, owner_id, title, description, weight, extra, progress, date, duration FROM togos WHERE owner_id=? ORDER BY date"
-		/* if justToday {
-			today := Date{time.Now()}
-			next := Date{today.AddDate(0, 0, 1)}
-			fmt.Println(next.Short())
-			SELECT_QUERY = fmt.Sprintf("%s WHERE date >= DATETIME(%s)", SELECT_QUERY, today.Short())//, next.Short())
-			fmt.Println(SELECT_QUERY)
-		}*/
-		rows, e := db.Query(SELECT_QUERY, ownerId)
+		rows, e := load.Query(args...)
 		if e != nil {
 			err = e
 			return
 		}
+		defer rows.Close()
 
-		now := Today()
 		for rows.Next() {
-			var togo Togo
-			var date time.Time
-
-			err = rows.Scan(&togo.Id, &togo.OwnerId, &togo.Title, &togo.Description, &togo.Weight, &togo.Extra, &togo.Progress, &date, &togo.Duration)
-			if timezone, err := time.LoadLocation("Asia/Tehran"); err == nil {
-				togo.Date = Date{date.In(timezone)}
-			} else {
-				togo.Date = Date{date}
-			}
-			togo.Duration *= time.Minute
-			if err != nil {
+			togo, e := scanTogo(rows)
+			if e != nil {
 				currupted_rows++
 				continue
 			}
-			if togo.Date.Short() == now.Short() || !justToday {
-				togos = togos.Add(&togo)
-			}
+			togos = togos.Add(&togo)
 		}
 	} else {
 		err = e
@@ -348,36 +417,30 @@ func Load(ownerId int64, justToday bool) (togos TogoList, err error) {
 }
 
 func LoadEverybodysToday() (TogoList, error) {
+	today := Today()
+	return LoadEverybodysBetween(today.Time, today.AddDate(0, 0, 1))
+}
+
+// LoadEverybodysBetween loads the togos of all users that start in [from, to)
+func LoadEverybodysBetween(from time.Time, to time.Time) (TogoList, error) {
 
 	togos := make(TogoList, 0)
 	currupted_rows := 0
-	if db, err := sql.Open("sqlite3", DATABASE_NAME); err == nil {
-		defer db.Close()
-		const SELECT_QUERY string = "SELECT id, owner_id, title, description, weight, extra, progress, date, duration FROM togos WHERE date BETWEEN ? AND ? ORDER BY date"
-		today := Today()
-
-		rows, err := db.Query(SELECT_QUERY, today.Time, today.AddDate(0, 0, 1))
+	// dates are stored as text with their time zone, so they're compared in UTC by datetime()
+	if load, err := statement(SELECT_TOGOS_QUERY + " WHERE datetime(date) >= datetime(?) AND datetime(date) < datetime(?) ORDER BY date"); err == nil {
+		rows, err := load.Query(from, to)
 		if err != nil {
 			return nil, err
 		}
+		defer rows.Close()
 
 		for rows.Next() {
-			var togo Togo
-			var date time.Time
-
-			err = rows.Scan(&togo.Id, &togo.OwnerId, &togo.Title, &togo.Description, &togo.Weight, &togo.Extra, &togo.Progress, &date, &togo.Duration)
+			togo, err := scanTogo(rows)
 			if err != nil {
 				currupted_rows++
 				continue
 			}
 
-			if timezone, err := time.LoadLocation("Asia/Tehran"); err == nil {
-				togo.Date = Date{date.In(timezone)}
-			} else {
-				togo.Date = Date{date}
-			}
-			togo.Duration *= time.Minute
-
 			togos = togos.Add(&togo)
 		}
 	} else {
@@ -391,13 +454,14 @@ func LoadEverybodysToday() (TogoList, error) {
 	return togos, warning
 }
 
-func Extract(ownerId int64, terms []string) (togo Togo) {
+// Extract makes a togo of the terms of + command; the weight is defaultWeight unless it's set in the terms
+func Extract(ownerId int64, terms []string, defaultWeight uint16) (togo Togo) {
 	// setting default values
 	if togo.Title = terms[0]; togo.Title == "" {
 		togo.Title = "Untitled"
 	}
 	togo.OwnerId = ownerId
-	togo.Weight = 1
+	togo.Weight = defaultWeight
 	togo.Date = Today()
 	(&togo).setFields(terms)
 	return
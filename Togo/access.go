@@ -0,0 +1,111 @@
+package ToGo4BotPlus
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const CREATE_ACCESS_TABLES_QUERY string = `CREATE TABLE IF NOT EXISTS access_grants (chat_id BIGINT PRIMARY KEY, granted_by BIGINT, granted_at DATETIME);
+	CREATE TABLE IF NOT EXISTS invites (code CHAR(16) PRIMARY KEY, created_by BIGINT NOT NULL, created_at DATETIME,
+	redeemed_by BIGINT, redeemed_at DATETIME)`
+
+const InviteCodeLength = 8 // bytes; the code itself is twice as long in hex
+
+// ---------------------- Access Functions --------------------------------
+// IsGranted checks if any of the ids (user or chat) is allowed to use the bot
+func IsGranted(ids ...int64) (bool, error) {
+	selectGrant, err := statement("SELECT COUNT(*) FROM access_grants WHERE chat_id=?")
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		var count int
+		if err := selectGrant.QueryRow(id).Scan(&count); err != nil {
+			return false, err
+		} else if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+const INSERT_GRANT_QUERY string = "INSERT OR REPLACE INTO access_grants (chat_id, granted_by, granted_at) VALUES (?, ?, ?)"
+
+func Grant(chatId int64, grantedBy int64) error {
+	grant, err := statement(INSERT_GRANT_QUERY)
+	if err != nil {
+		return err
+	}
+	_, err = grant.Exec(chatId, grantedBy, time.Now())
+	return err
+}
+
+func Revoke(chatId int64) error {
+	revoke, err := statement("DELETE FROM access_grants WHERE chat_id=?")
+	if err != nil {
+		return err
+	}
+	_, err = revoke.Exec(chatId)
+	return err
+}
+
+func CreateInvite(createdBy int64) (string, error) {
+	insert, err := statement("INSERT INTO invites (code, created_by, created_at) VALUES (?, ?, ?)")
+	if err != nil {
+		return "", err
+	}
+	random := make([]byte, InviteCodeLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(random)
+	if _, err := insert.Exec(code, createdBy, time.Now()); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemInvite uses up an invite code and grants access to the chat, in a single transaction
+func RedeemInvite(code string, chatId int64) error {
+	db, err := getDatabase()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	redeem, err := txStatement(tx, "UPDATE invites SET redeemed_by=?, redeemed_at=? WHERE code=? AND redeemed_by IS NULL")
+	if err != nil {
+		return err
+	}
+	creator, err := txStatement(tx, "SELECT created_by FROM invites WHERE code=?")
+	if err != nil {
+		return err
+	}
+	grant, err := txStatement(tx, INSERT_GRANT_QUERY)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	result, err := redeem.Exec(chatId, now, code)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return errors.New("this invite code is invalid or already used")
+	}
+	var createdBy int64
+	if err := creator.QueryRow(code).Scan(&createdBy); err != nil {
+		return err
+	}
+	if _, err := grant.Exec(chatId, createdBy, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,101 @@
+package ToGo4BotPlus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ---------------------- Backup & Restore --------------------------------
+// Backup writes a consistent snapshot of the database into path, while the bot is still running.
+func Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return errors.New("backup file already exists: " + path)
+	}
+	db, err := getDatabase()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// CheckSnapshot makes sure the file at path is a healthy sqlite database
+func CheckSnapshot(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return errors.New("snapshot is corrupted: " + result)
+	}
+	return nil
+}
+
+// Restore copies the snapshot at path into the database with the sqlite online backup API, so the database
+// handle stays valid; the other connections see either the old or the new data. The snapshot may be older than
+// the running bot, so its tables are migrated by Use, which prepares the statements again too.
+func Restore(path string) error {
+	if err := CheckSnapshot(path); err != nil {
+		return err
+	}
+	db, err := getDatabase()
+	if err != nil {
+		return err
+	}
+	if err := copySnapshot(db, path); err != nil {
+		return err
+	}
+	return Use(db)
+}
+
+// copySnapshot replaces the main database of db with the one at path
+func copySnapshot(db *sql.DB, path string) error {
+	snapshot, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	ctx := context.Background()
+	source, err := snapshot.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	return target.Raw(func(targetConn interface{}) error {
+		return source.Raw(func(sourceConn interface{}) error {
+			to, ok := targetConn.(*sqlite3.SQLiteConn)
+			from, ok2 := sourceConn.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return errors.New("restore only works on sqlite databases")
+			}
+			backup, err := to.Backup("main", from, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
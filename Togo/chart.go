@@ -0,0 +1,187 @@
+package ToGo4BotPlus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sort"
+)
+
+const (
+	ChartWidth       = 900
+	ChartHeight      = 420
+	ChartMarginLeft  = 60
+	ChartMarginRight = 20
+	ChartMarginTop   = 30
+	ChartMarginBelow = 40
+	ChartFontScale   = 2
+)
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartGrid       = color.RGBA{225, 225, 225, 255}
+	chartAxis       = color.RGBA{90, 90, 90, 255}
+	chartText       = color.RGBA{60, 60, 60, 255}
+	chartDone       = color.RGBA{76, 175, 80, 255}
+	chartUndone     = color.RGBA{255, 152, 0, 255}
+	chartPlanned    = color.RGBA{207, 216, 220, 255}
+	chartTarget     = color.RGBA{229, 57, 53, 255}
+)
+
+// 3x5 pixel glyphs, each row is a 3 bit mask; enough for writing numbers on the axes
+var chartGlyphs = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {7, 1, 7, 4, 7}, '3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1}, '5': {7, 4, 7, 1, 7}, '6': {7, 4, 7, 5, 7}, '7': {7, 1, 1, 1, 1},
+	'8': {7, 5, 7, 5, 7}, '9': {7, 5, 7, 1, 7}, '%': {5, 1, 2, 4, 5}, '.': {0, 0, 0, 0, 2},
+	'-': {0, 0, 7, 0, 0}, '/': {1, 1, 2, 4, 4}, 'x': {0, 5, 2, 5, 0},
+}
+
+// ---------------------- Chart Struct & Receivers --------------------------------
+type chart struct {
+	*image.RGBA
+	plot     image.Rectangle
+	maxValue float64
+}
+
+func newChart(maxValue float64) *chart {
+	c := &chart{RGBA: image.NewRGBA(image.Rect(0, 0, ChartWidth, ChartHeight)),
+		plot: image.Rect(ChartMarginLeft, ChartMarginTop, ChartWidth-ChartMarginRight, ChartHeight-ChartMarginBelow)}
+	// round the maximum value up to the next multiple of 25
+	c.maxValue = math.Max(100, math.Ceil(maxValue/25)*25)
+	c.fill(c.Bounds(), chartBackground)
+	for value := 0.0; value <= c.maxValue; value += 25 {
+		y := c.y(value)
+		c.fill(image.Rect(c.plot.Min.X, y, c.plot.Max.X, y+1), chartGrid)
+		label := fmt.Sprint(value, "%")
+		c.text(c.plot.Min.X-8-c.textWidth(label), y-2*ChartFontScale, label)
+	}
+	c.fill(image.Rect(c.plot.Min.X-1, c.plot.Min.Y, c.plot.Min.X+1, c.plot.Max.Y), chartAxis)
+	c.fill(image.Rect(c.plot.Min.X, c.plot.Max.Y-1, c.plot.Max.X, c.plot.Max.Y+1), chartAxis)
+	return c
+}
+
+func (c *chart) fill(rect image.Rectangle, col color.Color) {
+	draw.Draw(c.RGBA, rect, &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+func (c *chart) y(value float64) int {
+	if value > c.maxValue {
+		value = c.maxValue
+	}
+	return c.plot.Max.Y - int(value/c.maxValue*float64(c.plot.Dy()))
+}
+
+func (c *chart) textWidth(text string) int {
+	return len(text) * 4 * ChartFontScale
+}
+
+func (c *chart) text(x int, y int, text string) {
+	for _, char := range text {
+		if glyph, ok := chartGlyphs[char]; ok {
+			for row := range glyph {
+				for col := 0; col < 3; col++ {
+					if glyph[row]&(4>>col) != 0 {
+						px, py := x+col*ChartFontScale, y+row*ChartFontScale
+						c.fill(image.Rect(px, py, px+ChartFontScale, py+ChartFontScale), chartText)
+					}
+				}
+			}
+		}
+		x += 4 * ChartFontScale
+	}
+}
+
+func (c *chart) bar(slot int, slots int, value float64, col color.Color) image.Rectangle {
+	width := c.plot.Dx() / slots
+	x := c.plot.Min.X + slot*width + width/6
+	rect := image.Rect(x, c.y(value), x+width-width/3, c.plot.Max.Y)
+	c.fill(rect, col)
+	return rect
+}
+
+func (c *chart) label(rect image.Rectangle, text string) {
+	c.text(rect.Min.X+(rect.Dx()-c.textWidth(text))/2, c.plot.Max.Y+8, text)
+}
+
+func (c *chart) encode() ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, c); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// ---------------------- Chart Functions --------------------------------
+// DailyChart draws a bar chart of the progress made in each day, with the target as a red line.
+func DailyChart(days []DayProgress, target float64) ([]byte, error) {
+	if len(days) == 0 {
+		return nil, errors.New("there is no day to draw")
+	}
+	maxValue := target
+	for _, day := range days {
+		maxValue = math.Max(maxValue, day.Progress)
+	}
+	c := newChart(maxValue)
+	for i, day := range days {
+		col := chartUndone
+		if day.Progress >= target {
+			col = chartDone
+		}
+		rect := c.bar(i, len(days), day.Progress, col)
+		c.label(rect, fmt.Sprint(day.Day.Day()))
+	}
+	if target > 0 {
+		y := c.y(target)
+		for x := c.plot.Min.X; x < c.plot.Max.X; x += 12 {
+			c.fill(image.Rect(x, y-1, x+6, y+1), chartTarget)
+		}
+	}
+	return c.encode()
+}
+
+type WeightProgress struct {
+	Weight   uint16
+	Progress float64 // average progress of togos with this weight
+	Count    int
+}
+
+func (togos TogoList) ProgressByWeight() (result []WeightProgress) {
+	groups := make(map[uint16]*WeightProgress)
+	for i := range togos {
+		group, ok := groups[togos[i].Weight]
+		if !ok {
+			group = &WeightProgress{Weight: togos[i].Weight}
+			groups[togos[i].Weight] = group
+		}
+		group.Progress += float64(togos[i].Progress)
+		group.Count++
+	}
+	for _, group := range groups {
+		group.Progress /= float64(group.Count)
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Weight < result[j].Weight })
+	return
+}
+
+// WeightChart draws the average progress of togos grouped by their weight;
+// the grey bar behind each group stands for the planned 100%.
+func WeightChart(weights []WeightProgress) ([]byte, error) {
+	if len(weights) == 0 {
+		return nil, errors.New("there is no togo to draw")
+	}
+	c := newChart(100)
+	for i, weight := range weights {
+		c.bar(i, len(weights), 100, chartPlanned)
+		rect := c.bar(i, len(weights), weight.Progress, chartDone)
+		c.label(rect, fmt.Sprint("x", weight.Weight))
+		value := fmt.Sprintf("%.0f%%", weight.Progress)
+		c.text(rect.Min.X+(rect.Dx()-c.textWidth(value))/2, rect.Min.Y-7*ChartFontScale, value)
+	}
+	return c.encode()
+}
@@ -0,0 +1,129 @@
+package ToGo4BotPlus
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DatabaseBusyTimeout        = 5000 // milliseconds
+	DatabaseMaxOpenConnections = 4
+)
+
+// all the tables of the package, created in this order
+var tables = []string{CREATE_TABLE_QUERY, CREATE_TOGOS_INDEXES_QUERY, CREATE_USERS_TABLES_QUERY, CREATE_ACCESS_TABLES_QUERY,
+	CREATE_STATE_TABLE_QUERY, CREATE_REMINDERS_TABLE_QUERY, CREATE_TEMPLATES_TABLES_QUERY, CREATE_SHARES_TABLE_QUERY,
+	CREATE_SETTINGS_TABLE_QUERY}
+
+// columns added after the tables were first created; sqlite has no ADD COLUMN IF NOT EXISTS,
+// so the "duplicate column" error of the databases that have them already is ignored
+var migrations = []string{
+	"ALTER TABLE togos ADD COLUMN rollovers INTEGER DEFAULT 0",
+	"ALTER TABLE users ADD COLUMN rollover INTEGER DEFAULT 0",
+	"ALTER TABLE togos ADD COLUMN assignee VARCHAR(64) DEFAULT ''",
+	"ALTER TABLE users ADD COLUMN language CHAR(2) DEFAULT ''",
+	"ALTER TABLE users ADD COLUMN calendar VARCHAR(16) DEFAULT ''",
+	"ALTER TABLE togos ADD COLUMN skipped INTEGER DEFAULT 0",
+}
+
+var (
+	database     *sql.DB
+	statements   = make(map[string]*sql.Stmt)
+	statementsMu sync.Mutex // guards database too
+)
+
+// ---------------------- Database Functions --------------------------------
+// OpenDatabase opens a connection pool to the sqlite database at path, in WAL mode (readers don't wait for the writer)
+// and with a busy timeout, so that concurrent writers wait for each other instead of failing.
+func OpenDatabase(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+fmt.Sprint("?_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate&_busy_timeout=", DatabaseBusyTimeout))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(DatabaseMaxOpenConnections)
+	db.SetMaxIdleConns(DatabaseMaxOpenConnections)
+	db.SetConnMaxIdleTime(10 * time.Minute)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Use injects the database handle that all the functions of this package use, and creates or migrates the tables if needed
+func Use(db *sql.DB) error {
+	for _, query := range tables {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+	for _, query := range migrations {
+		if _, err := db.Exec(query); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+	statementsMu.Lock()
+	defer statementsMu.Unlock()
+	for query, statement := range statements {
+		statement.Close()
+		delete(statements, query)
+	}
+	database = db
+	return nil
+}
+
+// Close closes the prepared statements and the injected database
+func Close() error {
+	statementsMu.Lock()
+	defer statementsMu.Unlock()
+	for query, statement := range statements {
+		statement.Close()
+		delete(statements, query)
+	}
+	if database == nil {
+		return nil
+	}
+	err := database.Close()
+	database = nil
+	return err
+}
+
+func getDatabase() (*sql.DB, error) {
+	statementsMu.Lock()
+	defer statementsMu.Unlock()
+	if database == nil {
+		return nil, errors.New("database is not opened yet")
+	}
+	return database, nil
+}
+
+// statement returns the prepared statement of the query; each query is prepared once and used concurrently after that
+func statement(query string) (*sql.Stmt, error) {
+	statementsMu.Lock()
+	defer statementsMu.Unlock()
+	if prepared, ok := statements[query]; ok {
+		return prepared, nil
+	}
+	if database == nil {
+		return nil, errors.New("database is not opened yet")
+	}
+	prepared, err := database.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	statements[query] = prepared
+	return prepared, nil
+}
+
+// txStatement is the prepared statement of the query, bound to the transaction
+func txStatement(tx *sql.Tx, query string) (*sql.Stmt, error) {
+	prepared, err := statement(query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Stmt(prepared), nil
+}
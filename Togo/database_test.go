@@ -0,0 +1,120 @@
+package ToGo4BotPlus
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const benchmarkOwner int64 = 1
+
+// benchmarkTogo is the togo saved by the benchmarks
+func benchmarkTogo(i int) Togo {
+	return Togo{OwnerId: benchmarkOwner, Title: fmt.Sprint("togo ", i), Weight: 1, Progress: uint8(i % 100),
+		Date: Date{time.Now().Add(time.Duration(i) * time.Minute)}}
+}
+
+// useTemporaryDatabase opens a fresh database in a temporary directory and injects it, as main does
+func useTemporaryDatabase(b *testing.B) string {
+	path := filepath.Join(b.TempDir(), "togos.db")
+	db, err := OpenDatabase(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := Use(db); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { Close() })
+	return path
+}
+
+// saveOpeningDatabase is how Save worked before the shared pool: a connection opened and closed per call
+func saveOpeningDatabase(path string, togo *Togo) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.Exec(CREATE_TABLE_QUERY); err != nil {
+		return err
+	}
+	_, err = db.Exec(INSERT_TOGO_QUERY, togo.OwnerId, togo.Title, togo.Description, togo.Weight, togo.Extra, togo.Progress,
+		togo.Date.Time, togo.Duration.Minutes(), togo.Assignee)
+	return err
+}
+
+// loadOpeningDatabase is how Load worked before the shared pool
+func loadOpeningDatabase(path string, ownerId int64) (togos TogoList, err error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(SELECT_TOGOS_QUERY+" WHERE owner_id=? ORDER BY date", ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		togo, err := scanTogo(rows)
+		if err != nil {
+			return nil, err
+		}
+		togos = togos.Add(&togo)
+	}
+	return togos, rows.Err()
+}
+
+func BenchmarkSave(b *testing.B) {
+	b.Run("OpenPerCall", func(b *testing.B) {
+		path := useTemporaryDatabase(b)
+		for i := 0; i < b.N; i++ {
+			togo := benchmarkTogo(i)
+			if err := saveOpeningDatabase(path, &togo); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("SharedPool", func(b *testing.B) {
+		useTemporaryDatabase(b)
+		for i := 0; i < b.N; i++ {
+			togo := benchmarkTogo(i)
+			if _, err := togo.Save(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkLoad(b *testing.B) {
+	const rows = 20 // about the togos of a few days; the bigger loads are dominated by reading the rows, not by opening the database
+	prepare := func(b *testing.B) string {
+		path := useTemporaryDatabase(b)
+		for i := 0; i < rows; i++ {
+			togo := benchmarkTogo(i)
+			if _, err := togo.Save(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ResetTimer()
+		return path
+	}
+	b.Run("OpenPerCall", func(b *testing.B) {
+		path := prepare(b)
+		for i := 0; i < b.N; i++ {
+			if togos, err := loadOpeningDatabase(path, benchmarkOwner); err != nil || len(togos) != rows {
+				b.Fatal(len(togos), err)
+			}
+		}
+	})
+	b.Run("SharedPool", func(b *testing.B) {
+		prepare(b)
+		for i := 0; i < b.N; i++ {
+			if togos, err := Load(benchmarkOwner, AllDays()); err != nil || len(togos) != rows {
+				b.Fatal(len(togos), err)
+			}
+		}
+	})
+}
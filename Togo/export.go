@@ -0,0 +1,71 @@
+package ToGo4BotPlus
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	ExportVersion       = 1
+	CSVExportDateFormat = "2006-01-02 15:04"
+)
+
+// ---------------------- Export Structs --------------------------------
+// ExportedTogo is the json schema of a togo in exported files; ParseJSON reads the same schema back
+type ExportedTogo struct {
+	Id          uint64    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Weight      uint16    `json:"weight"`
+	Progress    uint8     `json:"progress"`
+	Extra       bool      `json:"extra"`
+	Date        time.Time `json:"date"`
+	Duration    int64     `json:"duration"` // in minutes
+	Assignee    string    `json:"assignee,omitempty"`
+	Rollovers   uint16    `json:"rollovers,omitempty"`
+}
+
+type ExportFile struct {
+	Version    int            `json:"version"`
+	OwnerId    int64          `json:"owner_id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Togos      []ExportedTogo `json:"togos"`
+}
+
+// ---------------------- Export Receivers --------------------------------
+func (togo *Togo) Export() ExportedTogo {
+	return ExportedTogo{Id: togo.Id, Title: togo.Title, Description: togo.Description, Weight: togo.Weight,
+		Progress: togo.Progress, Extra: togo.Extra, Date: togo.Date.Time, Duration: int64(togo.Duration.Minutes()),
+		Assignee: togo.Assignee, Rollovers: togo.Rollovers}
+}
+
+func (exported *ExportedTogo) ToTogo(ownerId int64) Togo {
+	return Togo{Title: exported.Title, Description: exported.Description, Weight: exported.Weight, Progress: exported.Progress,
+		Extra: exported.Extra, Date: Date{exported.Date}.ToLocal(), Duration: time.Duration(exported.Duration) * time.Minute, OwnerId: ownerId,
+		Assignee: exported.Assignee, Rollovers: exported.Rollovers}
+}
+
+func (togos TogoList) JSON(ownerId int64) ([]byte, error) {
+	file := ExportFile{Version: ExportVersion, OwnerId: ownerId, ExportedAt: time.Now(), Togos: make([]ExportedTogo, len(togos))}
+	for i := range togos {
+		file.Togos[i] = togos[i].Export()
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// CSV writes the togos with the same header that ParseCSV expects
+func (togos TogoList) CSV() ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(CSVHeader)
+	for i := range togos {
+		writer.Write([]string{togos[i].Title, togos[i].Description, fmt.Sprint(togos[i].Weight), fmt.Sprint(togos[i].Progress),
+			fmt.Sprint(togos[i].Extra), togos[i].Date.ToLocal().Format(CSVExportDateFormat), fmt.Sprint(int64(togos[i].Duration.Minutes())),
+			togos[i].Assignee, fmt.Sprint(togos[i].Rollovers)})
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
@@ -0,0 +1,104 @@
+package ToGo4BotPlus
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+)
+
+const ProgressBarLength = 10
+
+// ---------------------- HTML Rendering --------------------------------
+// Texts rendered here are sent with telegram's HTML parse mode, so every text typed by users is escaped;
+// tags never span more than one line, so long messages can be split between lines safely.
+
+// ProgressBar draws the progress (0 to 100) like ▓▓▓▓▓░░░░░
+func ProgressBar(progress uint8) string {
+	if progress > 100 {
+		progress = 100
+	}
+	filled := int(progress) * ProgressBarLength / 100
+	return strings.Repeat("▓", filled) + strings.Repeat("░", ProgressBarLength-filled)
+}
+
+// HTML is the togo as a few lines of telegram HTML: the title in bold, its time, details and progress bar
+func (togo *Togo) HTML(locale Locale) string {
+	title := fmt.Sprintf("<b>%s</b>  #%d", html.EscapeString(togo.Title), togo.Id)
+	if togo.Progress >= 100 {
+		title = "✅ " + title
+	} else if togo.Skipped {
+		title = "⏭ " + title
+	}
+	lines := []string{title}
+	if togo.Description != "" {
+		for _, line := range strings.Split(togo.Description, "\n") {
+			lines = append(lines, "<i>"+html.EscapeString(line)+"</i>")
+		}
+	}
+	when := fmt.Sprintf("🕘 %02d:%02d", togo.Date.Hour(), togo.Date.Minute())
+	if togo.Duration > 0 {
+		end := togo.Date.Add(togo.Duration)
+		when += fmt.Sprintf(" → %02d:%02d", end.Hour(), end.Minute())
+	}
+	when += "  " + locale.Text("weight", togo.Weight)
+	if togo.Extra {
+		when += "  " + locale.Text("extra", locale.Text("true"))
+	}
+	lines = append(lines, when, fmt.Sprintf("<code>%s</code> %d%%", ProgressBar(togo.Progress), togo.Progress))
+	if togo.Assignee != "" {
+		lines = append(lines, html.EscapeString(locale.Text("assigned_to", togo.Assignee)))
+	}
+	if togo.Rollovers > 0 {
+		lines = append(lines, locale.Text("rollovers", togo.Rollovers))
+	}
+	if togo.Skipped {
+		lines = append(lines, locale.Text("skip_mark"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SortByTime sorts the togos by their date, in place; togos at the same time keep their order
+func (togos TogoList) SortByTime() TogoList {
+	sort.SliceStable(togos, func(i, j int) bool { return togos[i].Date.Before(togos[j].Date.Time) })
+	return togos
+}
+
+// ListingHTML renders the togos as one HTML text per group (see Group), with the separator line between the togos;
+// each group starts with its subtotal progress. note can add a line under each togo (it may return ""), and can be nil
+func (togos TogoList) ListingHTML(options ListingOptions, locale Locale, separator string, note func(togo *Togo) string) []string {
+	texts := make([]string, 0)
+	for _, group := range togos.Group(options, locale) {
+		blocks := []string{"<b>" + group.Title + "</b>  " + group.Togos.SubtotalHTML()}
+		for i := range group.Togos {
+			if options.JustUndones && (group.Togos[i].Progress >= 100 || group.Togos[i].Skipped) {
+				continue
+			}
+			block := group.Togos[i].HTML(locale)
+			if note != nil {
+				if line := note(&group.Togos[i]); line != "" {
+					block += "\n" + html.EscapeString(line)
+				}
+			}
+			if len(blocks) > 1 {
+				block = separator + "\n" + block
+			}
+			blocks = append(blocks, block)
+		}
+		if len(blocks) > 1 {
+			texts = append(texts, locale.Align(strings.Join(blocks, "\n")))
+		}
+	}
+	return texts
+}
+
+// SubtotalHTML is the progress made on the togos, with a progress bar
+func (togos TogoList) SubtotalHTML() string {
+	progress, _, completed, extra, total := togos.Subtotal()
+	subtotal := fmt.Sprintf("<code>%s</code> %3.2f%% (%d / %d)", ProgressBar(uint8(math.Min(progress, 100))), progress, completed, total)
+	if extra > 0 {
+		subtotal += fmt.Sprintf("[+%d]", extra)
+	}
+	return subtotal
+}
@@ -0,0 +1,87 @@
+package ToGo4BotPlus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ICalendarTimeFormat     = "20060102T150405Z"
+	ICalendarMaxLineLength  = 75
+	ICalendarProductId      = "-//pya-h//ToGo4BotPlus//EN"
+	ICalendarUIDDomain      = "togo4bot"
+	ICalendarReminderBefore = time.Minute // same as the bot notifications
+)
+
+// ---------------------- iCalendar Helpers --------------------------------
+func escapeICalendarText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// foldICalendarLine splits the lines longer than 75 octets, as RFC 5545 wants, without breaking utf-8 characters
+func foldICalendarLine(line string) string {
+	var folded strings.Builder
+	length := 0
+	for _, char := range line {
+		size := len(string(char))
+		if length+size > ICalendarMaxLineLength {
+			folded.WriteString("\r\n ")
+			length = 1
+		}
+		folded.WriteRune(char)
+		length += size
+	}
+	return folded.String()
+}
+
+func formatICalendarDuration(duration time.Duration) string {
+	if duration < 0 {
+		return fmt.Sprintf("-PT%dM", int64(-duration.Minutes()))
+	}
+	return fmt.Sprintf("PT%dM", int64(duration.Minutes()))
+}
+
+// ---------------------- iCalendar Receivers --------------------------------
+func (togo *Togo) ICalendarEvent(stamp time.Time) []string {
+	extra := 0
+	if togo.Extra {
+		extra = 1
+	}
+	return []string{
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:togo-%d-%d@%s", togo.OwnerId, togo.Id, ICalendarUIDDomain),
+		"DTSTAMP:" + stamp.UTC().Format(ICalendarTimeFormat),
+		"DTSTART:" + togo.Date.UTC().Format(ICalendarTimeFormat),
+		"DURATION:" + formatICalendarDuration(togo.Duration),
+		"SUMMARY:" + escapeICalendarText(togo.Title),
+		"DESCRIPTION:" + escapeICalendarText(togo.Description),
+		// togo specific fields, so that importing the file again gives the same togos
+		fmt.Sprint("X-TOGO-WEIGHT:", togo.Weight),
+		fmt.Sprint("X-TOGO-PROGRESS:", togo.Progress),
+		fmt.Sprint("X-TOGO-EXTRA:", extra),
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:" + formatICalendarDuration(-ICalendarReminderBefore),
+		"DESCRIPTION:" + escapeICalendarText(togo.Title),
+		"END:VALARM",
+		"END:VEVENT",
+	}
+}
+
+// ICalendar returns an .ics file containing one VEVENT for each togo
+func (togos TogoList) ICalendar() []byte {
+	stamp := time.Now()
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:" + ICalendarProductId, "CALSCALE:GREGORIAN", "METHOD:PUBLISH"}
+	for i := range togos {
+		lines = append(lines, togos[i].ICalendarEvent(stamp)...)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var result strings.Builder
+	for _, line := range lines {
+		result.WriteString(foldICalendarLine(line))
+		result.WriteString("\r\n")
+	}
+	return []byte(result.String())
+}
@@ -0,0 +1,386 @@
+package ToGo4BotPlus
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the header of csv files; only title is mandatory and columns can come in any order
+var CSVHeader = []string{"title", "description", "weight", "progress", "extra", "date", "duration", "assignee", "rollovers"}
+
+// like INSERT_TOGO_QUERY, keeping the rollovers of the exported togos too
+const IMPORT_TOGO_QUERY string = `INSERT INTO togos (owner_id, title, description, weight, extra, progress, date, duration, assignee, rollovers)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+var CSVDateFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ---------------------- Import Structs --------------------------------
+type ImportIssue struct {
+	Item   string
+	Reason string
+}
+
+type ImportReport struct {
+	Created    int
+	Duplicates []ImportIssue
+	Rejected   []ImportIssue
+}
+
+func (report *ImportReport) ToString() string {
+	return report.Localize(DefaultLocale)
+}
+
+func (report *ImportReport) Localize(locale Locale) string {
+	result := locale.Text("import_report", report.Created, len(report.Duplicates), len(report.Rejected))
+	for _, issue := range report.Duplicates {
+		result += fmt.Sprintf("\n🔁 %s: %s", issue.Item, issue.Reason)
+	}
+	for _, issue := range report.Rejected {
+		result += fmt.Sprintf("\n⛔ %s: %s", issue.Item, issue.Reason)
+	}
+	return result
+}
+
+// ---------------------- Import Helpers --------------------------------
+func unescapeICalendarText(text string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(text)
+}
+
+var iCalendarDurationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICalendarDuration(value string) (time.Duration, error) {
+	parts := iCalendarDurationPattern.FindStringSubmatch(value)
+	if parts == nil {
+		return 0, errors.New("invalid duration: " + value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+	for i, unit := range units {
+		if parts[i+2] != "" {
+			amount, _ := strconv.Atoi(parts[i+2])
+			duration += time.Duration(amount) * unit
+		}
+	}
+	if parts[1] == "-" {
+		duration = -duration
+	}
+	return duration, nil
+}
+
+func parseICalendarTime(value string, params map[string]string) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(ICalendarTimeFormat, value)
+	}
+	location := localTimezone
+	if tzid, ok := params["TZID"]; ok {
+		if timezone, err := time.LoadLocation(tzid); err == nil {
+			location = timezone
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		return time.ParseInLocation("20060102", value, location)
+	}
+	return time.ParseInLocation("20060102T150405", value, location)
+}
+
+// ---------------------- Parsers --------------------------------
+// ParseICalendar converts each VEVENT of an .ics file to a togo; events without a valid DTSTART are rejected
+func ParseICalendar(ownerId int64, data []byte) (togos TogoList, rejected []ImportIssue) {
+	togos = make(TogoList, 0)
+	// unfold the lines first
+	content := strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(string(data))
+	var (
+		togo     *Togo
+		end      time.Time
+		hasStart bool
+		problem  error
+		depth    = 0 // VALARMs and other components inside VEVENT must be ignored
+		count    = 0
+	)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		separator := strings.Index(line, ":")
+		if separator < 0 {
+			continue
+		}
+		params := make(map[string]string)
+		nameParts := strings.Split(line[:separator], ";")
+		name, value := strings.ToUpper(nameParts[0]), line[separator+1:]
+		for _, param := range nameParts[1:] {
+			if pair := strings.SplitN(param, "=", 2); len(pair) == 2 {
+				params[strings.ToUpper(pair[0])] = strings.Trim(pair[1], `"`)
+			}
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			count++
+			togo = &Togo{OwnerId: ownerId, Weight: 1}
+			end, hasStart, problem, depth = time.Time{}, false, nil, 0
+		case togo == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+		case name == "END" && value != "VEVENT":
+			depth--
+		case name == "END":
+			item := fmt.Sprintf("event %d (%s)", count, togo.Title)
+			if problem == nil && !hasStart {
+				problem = errors.New("it has no DTSTART")
+			}
+			if problem == nil && !end.IsZero() && togo.Duration == 0 {
+				togo.Duration = end.Sub(togo.Date.Time)
+			}
+			if problem != nil {
+				rejected = append(rejected, ImportIssue{Item: item, Reason: problem.Error()})
+			} else if togo.Duration < 0 {
+				rejected = append(rejected, ImportIssue{Item: item, Reason: "duration must not be negative"})
+			} else {
+				if togo.Title == "" {
+					togo.Title = "Untitled"
+				}
+				togo.Duration = togo.Duration.Truncate(time.Minute)
+				togos = togos.Add(togo)
+			}
+			togo = nil
+		case depth > 0:
+			continue
+		case name == "SUMMARY":
+			togo.Title = unescapeICalendarText(value)
+		case name == "DESCRIPTION":
+			togo.Description = unescapeICalendarText(value)
+		case problem != nil:
+			continue // the first problem rejects the event; the properties after it can't clear it
+		case name == "DTSTART":
+			if start, err := parseICalendarTime(value, params); err == nil {
+				togo.Date = Date{start}.ToLocal()
+				hasStart = true
+			} else {
+				problem = err
+			}
+		case name == "DTEND":
+			if end, problem = parseICalendarTime(value, params); problem != nil {
+				end = time.Time{}
+			}
+		case name == "DURATION":
+			togo.Duration, problem = parseICalendarDuration(value)
+		case name == "X-TOGO-WEIGHT":
+			_, problem = fmt.Sscan(value, &togo.Weight)
+		case name == "X-TOGO-PROGRESS":
+			if _, problem = fmt.Sscan(value, &togo.Progress); problem == nil && togo.Progress > 100 {
+				problem = errors.New("progress must be between 0 and 100")
+			}
+		case name == "X-TOGO-EXTRA":
+			togo.Extra = value == "1"
+		}
+	}
+	return
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false", "no", "-x":
+		return false, nil
+	case "1", "true", "yes", "+x":
+		return true, nil
+	}
+	return false, errors.New("invalid boolean value: " + value)
+}
+
+// ParseCSV converts each row of a csv file, starting with CSVHeader columns, to a togo
+func ParseCSV(ownerId int64, data []byte) (togos TogoList, rejected []ImportIssue) {
+	togos = make(TogoList, 0)
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return togos, []ImportIssue{{Item: "header", Reason: err.Error()}}
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return togos, []ImportIssue{{Item: "header", Reason: "the header must contain at least the title column: " + strings.Join(CSVHeader, ",")}}
+	}
+
+	location := localTimezone
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		item := fmt.Sprint("row ", row)
+		if err != nil {
+			rejected = append(rejected, ImportIssue{Item: item, Reason: err.Error()})
+			continue
+		}
+		field := func(name string) string {
+			if index, ok := columns[name]; ok && index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		togo := Togo{OwnerId: ownerId, Title: field("title"), Description: field("description"), Weight: 1, Date: Today()}
+		if togo.Title == "" {
+			rejected = append(rejected, ImportIssue{Item: item, Reason: "title is empty"})
+			continue
+		}
+		item = fmt.Sprintf("%s (%s)", item, togo.Title)
+		var problem error
+		if value := field("weight"); value != "" {
+			_, problem = fmt.Sscan(value, &togo.Weight)
+		}
+		if value := field("progress"); value != "" && problem == nil {
+			if _, problem = fmt.Sscan(value, &togo.Progress); problem == nil && togo.Progress > 100 {
+				problem = errors.New("progress must be between 0 and 100")
+			}
+		}
+		if problem == nil {
+			togo.Extra, problem = parseBool(field("extra"))
+		}
+		if value := field("date"); value != "" && problem == nil {
+			problem = errors.New("unknown date format, use YYYY-MM-DD HH:MM")
+			for _, format := range CSVDateFormats {
+				if date, err := time.ParseInLocation(format, value, location); err == nil {
+					togo.Date, problem = Date{date}.ToLocal(), nil
+					break
+				}
+			}
+		}
+		if value := field("duration"); value != "" && problem == nil {
+			var minutes int64
+			if _, problem = fmt.Sscan(value, &minutes); problem == nil {
+				if minutes < 0 {
+					problem = errors.New("duration must not be negative")
+				}
+				togo.Duration = time.Duration(minutes) * time.Minute
+			}
+		}
+		if value := field("assignee"); value != "" && problem == nil {
+			togo.Assignee = Assignee(value)
+		}
+		if value := field("rollovers"); value != "" && problem == nil {
+			_, problem = fmt.Sscan(value, &togo.Rollovers)
+		}
+		if problem != nil {
+			rejected = append(rejected, ImportIssue{Item: item, Reason: problem.Error()})
+			continue
+		}
+		togos = togos.Add(&togo)
+	}
+	return
+}
+
+// ParseJSON reads the files made by TogoList.JSON
+func ParseJSON(ownerId int64, data []byte) (togos TogoList, rejected []ImportIssue) {
+	togos = make(TogoList, 0)
+	var file ExportFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return togos, []ImportIssue{{Item: "file", Reason: err.Error()}}
+	}
+	if file.Version > ExportVersion {
+		return togos, []ImportIssue{{Item: "file", Reason: fmt.Sprint("unsupported version: ", file.Version)}}
+	}
+	for i := range file.Togos {
+		item := fmt.Sprintf("togo %d (%s)", i+1, file.Togos[i].Title)
+		switch {
+		case file.Togos[i].Title == "":
+			rejected = append(rejected, ImportIssue{Item: item, Reason: "title is empty"})
+		case file.Togos[i].Progress > 100:
+			rejected = append(rejected, ImportIssue{Item: item, Reason: "progress must be between 0 and 100"})
+		case file.Togos[i].Duration < 0:
+			rejected = append(rejected, ImportIssue{Item: item, Reason: "duration must not be negative"})
+		case file.Togos[i].Date.IsZero():
+			rejected = append(rejected, ImportIssue{Item: item, Reason: "it has no date"})
+		default:
+			togo := file.Togos[i].ToTogo(ownerId)
+			togos = togos.Add(&togo)
+		}
+	}
+	return
+}
+
+// ---------------------- Import --------------------------------
+// Import saves the togos in a single transaction; togos with the same title and start time
+// as an existing togo (or a previous one in the same batch) are skipped as duplicates.
+func Import(ownerId int64, togos TogoList) (report ImportReport, err error) {
+	db, err := getDatabase()
+	if err != nil {
+		return
+	}
+	insert, err := statement(IMPORT_TOGO_QUERY)
+	if err != nil {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	created := make(TogoList, 0)
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			report.Created = 0
+		} else if err = tx.Commit(); err == nil {
+			countCreatedTogos(ownerId, report.Created)
+			for i := range created {
+				notifyChange(&created[i], false)
+			}
+		}
+	}()
+
+	key := func(title string, date time.Time) string {
+		return fmt.Sprint(title, "@", date.Truncate(time.Minute).Unix())
+	}
+	existing := make(map[string]bool)
+	rows, err := tx.Query("SELECT title, date FROM togos WHERE owner_id=?", ownerId)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var title string
+		var date time.Time
+		if rows.Scan(&title, &date) == nil {
+			existing[key(title, date)] = true
+		}
+	}
+	rows.Close()
+
+	for i := range togos {
+		item := fmt.Sprintf("%s at %s", togos[i].Title, togos[i].Date.Get())
+		if existing[key(togos[i].Title, togos[i].Date.Time)] {
+			report.Duplicates = append(report.Duplicates, ImportIssue{Item: item, Reason: "a togo with the same title and time exists"})
+			continue
+		}
+		extra := 0
+		if togos[i].Extra {
+			extra = 1
+		}
+		var result sql.Result
+		if result, err = tx.Stmt(insert).Exec(ownerId, togos[i].Title, togos[i].Description, togos[i].Weight, extra, togos[i].Progress,
+			togos[i].Date.Time, togos[i].Duration.Minutes(), togos[i].Assignee, togos[i].Rollovers); err != nil {
+			return
+		}
+		togo := togos[i]
+		togo.OwnerId = ownerId
+		if id, e := result.LastInsertId(); e == nil {
+			togo.Id = uint64(id)
+			created = created.Add(&togo)
+		}
+		existing[key(togos[i].Title, togos[i].Date.Time)] = true
+		report.Created++
+	}
+	return
+}
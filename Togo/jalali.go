@@ -0,0 +1,176 @@
+package ToGo4BotPlus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------- Jalali Calendar --------------------------------
+// The conversions follow the algorithm of Kazimierz M. Borkowski (as in jalaali-js), which matches the official
+// Iranian calendar for the Jalali years in the breaks range below; all divisions are truncated, like Go's / and %.
+
+// the years that the 33 years leap cycle breaks
+var jalaliBreaks = []int{-61, 9, 38, 199, 426, 686, 756, 818, 1111, 1181, 1210, 1635, 2060, 2097, 2192, 2262, 2324, 2394, 2456, 3178}
+
+// MinimumGregorianYear is the smallest year of absolute dates that is taken as gregorian; smaller ones are jalali
+const MinimumGregorianYear = 1700
+
+// jalaliCalendar returns the gregorian year of the start of the jalali year, the day of march it starts on,
+// and the number of years since the last leap year (0 means jy is leap)
+func jalaliCalendar(jy int) (gy int, march int, leap int, err error) {
+	count := len(jalaliBreaks)
+	if jy < jalaliBreaks[0] || jy >= jalaliBreaks[count-1] {
+		return 0, 0, 0, fmt.Errorf("jalali year %d is out of the supported range", jy)
+	}
+	gy = jy + 621
+	leapJ, jp, jump := -14, jalaliBreaks[0], 0
+	for i := 1; i < count; i++ {
+		jm := jalaliBreaks[i]
+		jump = jm - jp
+		if jy < jm {
+			break
+		}
+		leapJ += jump/33*8 + jump%33/4
+		jp = jm
+	}
+	n := jy - jp
+	leapJ += n/33*8 + (n%33+3)/4
+	if jump%33 == 4 && jump-n == 4 {
+		leapJ++
+	}
+	leapG := gy/4 - (gy/100+1)*3/4 - 150
+	march = 20 + leapJ - leapG
+	if jump-n < 6 {
+		n = n - jump + (jump+4)/33*33
+	}
+	if leap = ((n+1)%33 - 1) % 4; leap == -1 {
+		leap = 4
+	}
+	return
+}
+
+// gregorianToDayNumber is the julian day number of the gregorian date
+func gregorianToDayNumber(gy int, gm int, gd int) int {
+	d := (gy+(gm-8)/6+100100)*1461/4 + (153*((gm+9)%12)+2)/5 + gd - 34840408
+	return d - (gy+100100+(gm-8)/6)/100*3/4 + 752
+}
+
+func dayNumberToGregorian(jdn int) (gy int, gm int, gd int) {
+	j := 4*jdn + 139361631
+	j = j + (4*jdn+183187720)/146097*3/4*4 - 3908
+	i := j%1461/4*5 + 308
+	gd = i%153/5 + 1
+	gm = i/153%12 + 1
+	gy = j/1461 - 100100 + (8-gm)/6
+	return
+}
+
+func jalaliToDayNumber(jy int, jm int, jd int) (int, error) {
+	gy, march, _, err := jalaliCalendar(jy)
+	if err != nil {
+		return 0, err
+	}
+	return gregorianToDayNumber(gy, 3, march) + (jm-1)*31 - jm/7*(jm-7) + jd - 1, nil
+}
+
+func dayNumberToJalali(jdn int) (jy int, jm int, jd int, err error) {
+	gy, _, _ := dayNumberToGregorian(jdn)
+	jy = gy - 621
+	_, march, leap, err := jalaliCalendar(jy)
+	if err != nil {
+		return
+	}
+	k := jdn - gregorianToDayNumber(gy, 3, march)
+	if k >= 0 {
+		if k <= 185 {
+			return jy, 1 + k/31, k%31 + 1, nil
+		}
+		k -= 186
+	} else {
+		jy--
+		k += 179
+		if leap == 1 {
+			k++
+		}
+	}
+	return jy, 7 + k/30, k%30 + 1, nil
+}
+
+// IsJalaliLeapYear tells if the last month of the jalali year (Esfand) has 30 days
+func IsJalaliLeapYear(jy int) bool {
+	_, _, leap, err := jalaliCalendar(jy)
+	return err == nil && leap == 0
+}
+
+func JalaliMonthLength(jy int, jm int) int {
+	if jm <= 6 {
+		return 31
+	} else if jm <= 11 || IsJalaliLeapYear(jy) {
+		return 30
+	}
+	return 29
+}
+
+// ToJalali converts the date (in its own time zone) to the jalali calendar
+func ToJalali(date time.Time) (jy int, jm int, jd int) {
+	jy, jm, jd, err := dayNumberToJalali(gregorianToDayNumber(date.Year(), int(date.Month()), date.Day()))
+	if err != nil {
+		return date.Year(), int(date.Month()), date.Day() // out of range; just keep it gregorian
+	}
+	return
+}
+
+// FromJalali returns the start of the jalali day, in the location
+func FromJalali(jy int, jm int, jd int, location *time.Location) (time.Time, error) {
+	if jm < 1 || jm > 12 {
+		return time.Time{}, errors.New("month must be between 1 and 12")
+	} else if jd < 1 || jd > JalaliMonthLength(jy, jm) {
+		return time.Time{}, fmt.Errorf("day must be between 1 and %d", JalaliMonthLength(jy, jm))
+	}
+	jdn, err := jalaliToDayNumber(jy, jm, jd)
+	if err != nil {
+		return time.Time{}, err
+	}
+	gy, gm, gd := dayNumberToGregorian(jdn)
+	return time.Date(gy, time.Month(gm), gd, 0, 0, 0, 0, location), nil
+}
+
+// ParseDay reads an absolute date like 1403/07/28 or 2024-10-19; years before MinimumGregorianYear are jalali
+func ParseDay(day string, location *time.Location) (time.Time, error) {
+	parts := strings.FieldsFunc(day, func(r rune) bool { return r == '/' || r == '-' })
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("date must be like 1403/07/28 or 2024-10-19")
+	}
+	var numbers [3]int
+	for i := range parts {
+		number, err := strconv.Atoi(NormalizeDigits(parts[i]))
+		if err != nil {
+			return time.Time{}, errors.New("date must be like 1403/07/28 or 2024-10-19")
+		}
+		numbers[i] = number
+	}
+	if numbers[0] < MinimumGregorianYear {
+		return FromJalali(numbers[0], numbers[1], numbers[2], location)
+	}
+	date := time.Date(numbers[0], time.Month(numbers[1]), numbers[2], 0, 0, 0, 0, location)
+	if date.Month() != time.Month(numbers[1]) || date.Day() != numbers[2] {
+		return time.Time{}, errors.New("there is no such date")
+	}
+	return date, nil
+}
+
+// ---------------------- Jalali Date Receivers --------------------------------
+// JalaliGet is Get in the jalali calendar
+func (d *Date) JalaliGet() string {
+	jy, jm, jd := ToJalali(d.Time)
+	return fmt.Sprintf("%d-%d-%d\t%d:%d", jy, jm, jd, d.Hour(), d.Minute())
+}
+
+// JalaliShort is Short in the jalali calendar
+func (d *Date) JalaliShort() string {
+	jy, jm, jd := ToJalali(d.Time)
+	return fmt.Sprintf("%d-%d-%d", jy, jm, jd)
+}
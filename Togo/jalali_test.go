@@ -0,0 +1,55 @@
+package ToGo4BotPlus
+
+import (
+	"testing"
+	"time"
+)
+
+// known jalali dates and their gregorian equivalents, from the official iranian calendar; 1399, 1403 and 1408 are leap
+var jalaliTable = []struct {
+	Year, Month, Day int
+	Gregorian        string
+}{
+	{1300, 1, 1, "1921-03-21"},
+	{1357, 11, 22, "1979-02-11"},
+	{1375, 6, 31, "1996-09-21"},
+	{1395, 12, 30, "2017-03-20"},
+	{1399, 12, 30, "2021-03-20"},
+	{1400, 1, 1, "2021-03-21"},
+	{1402, 1, 1, "2023-03-21"},
+	{1403, 1, 1, "2024-03-20"},
+	{1403, 7, 28, "2024-10-19"},
+	{1403, 12, 30, "2025-03-20"},
+	{1404, 1, 1, "2025-03-21"},
+	{1405, 7, 27, "2026-10-19"},
+	{1408, 12, 30, "2030-03-20"},
+}
+
+// TestJalali converts the dates of the table both ways, and every day of 1900 to 2050 back and forth
+func TestJalali(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, known := range jalaliTable {
+		date, err := FromJalali(known.Year, known.Month, known.Day, location)
+		if err != nil {
+			t.Errorf("%d/%d/%d: %v", known.Year, known.Month, known.Day, err)
+			continue
+		} else if date.Format("2006-01-02") != known.Gregorian {
+			t.Errorf("%d/%d/%d became %s, not %s", known.Year, known.Month, known.Day, date.Format("2006-01-02"), known.Gregorian)
+		}
+		if year, month, day := ToJalali(date); year != known.Year || month != known.Month || day != known.Day {
+			t.Errorf("%s became %d/%d/%d, not %d/%d/%d", known.Gregorian, year, month, day, known.Year, known.Month, known.Day)
+		}
+	}
+	for date := time.Date(1900, 1, 1, 12, 0, 0, 0, location); date.Year() < 2050; date = date.AddDate(0, 0, 1) {
+		year, month, day := ToJalali(date)
+		back, err := FromJalali(year, month, day, location)
+		if err != nil {
+			t.Errorf("%s became %d/%d/%d: %v", date.Format("2006-01-02"), year, month, day, err)
+		} else if back.Format("2006-01-02") != date.Format("2006-01-02") {
+			t.Errorf("%s became %d/%d/%d and then %s", date.Format("2006-01-02"), year, month, day, back.Format("2006-01-02"))
+		}
+	}
+}
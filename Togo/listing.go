@@ -0,0 +1,155 @@
+package ToGo4BotPlus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SortKey string
+
+const (
+	SortByTime     SortKey = "time"     // earliest first
+	SortByWeight   SortKey = "weight"   // heaviest first
+	SortByProgress SortKey = "progress" // least done first
+	SortByTitle    SortKey = "title"    // alphabetical
+)
+
+var SortKeys = []SortKey{SortByTime, SortByWeight, SortByProgress, SortByTitle}
+
+type GroupKey string
+
+const (
+	GroupByDay    GroupKey = "day"
+	GroupByWeight GroupKey = "weight" // weight buckets, heaviest first
+	GroupByExtra  GroupKey = "extra"  // mandatory togos, then the extra ones
+)
+
+var GroupKeys = []GroupKey{GroupByDay, GroupByWeight, GroupByExtra}
+
+// the lowest weight of each bucket, heaviest first
+var weightBuckets = []uint16{10, 6, 4, 2, 1}
+
+// ---------------------- Listing Structs & Receivers --------------------------------
+// ListingOptions are how # lists the togos: in groups, each one sorted and with its own subtotal
+type ListingOptions struct {
+	SortBy      SortKey
+	GroupBy     GroupKey
+	JustUndones bool // the completed and skipped togos are counted in the subtotals, but not shown
+}
+
+var DefaultListingOptions = ListingOptions{SortBy: SortByTime, GroupBy: GroupByDay}
+
+type TogoGroup struct {
+	Title string
+	Togos TogoList
+}
+
+func ParseSortKey(name string) (SortKey, error) {
+	for _, key := range SortKeys {
+		if string(key) == strings.ToLower(name) {
+			return key, nil
+		}
+	}
+	return SortByTime, fmt.Errorf("togos can be sorted by %v", SortKeys)
+}
+
+func ParseGroupKey(name string) (GroupKey, error) {
+	for _, key := range GroupKeys {
+		if string(key) == strings.ToLower(name) {
+			return key, nil
+		}
+	}
+	return GroupByDay, fmt.Errorf("togos can be grouped by %v", GroupKeys)
+}
+
+// SortBy sorts the togos by the key, in place; togos with equal keys stay in the order of their time
+func (togos TogoList) SortBy(key SortKey) TogoList {
+	togos.SortByTime()
+	var less func(a *Togo, b *Togo) bool
+	switch key {
+	case SortByWeight:
+		less = func(a *Togo, b *Togo) bool { return a.Weight > b.Weight }
+	case SortByProgress:
+		less = func(a *Togo, b *Togo) bool { return a.Progress < b.Progress }
+	case SortByTitle:
+		less = func(a *Togo, b *Togo) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	default:
+		return togos
+	}
+	sort.SliceStable(togos, func(i, j int) bool { return less(&togos[i], &togos[j]) })
+	return togos
+}
+
+// GroupBy groups the togos by the key; the groups are in the order of the key, and empty ones are left out
+func (togos TogoList) GroupBy(key GroupKey, locale Locale) []TogoGroup {
+	groups := make([]TogoGroup, 0)
+	switch key {
+	case GroupByWeight:
+		buckets := make([]TogoList, len(weightBuckets))
+		for i := range togos {
+			for b, lowest := range weightBuckets {
+				if togos[i].Weight >= lowest || b == len(weightBuckets)-1 {
+					buckets[b] = buckets[b].Add(&togos[i])
+					break
+				}
+			}
+		}
+		for b, bucket := range buckets {
+			if len(bucket) == 0 {
+				continue
+			}
+			weights := fmt.Sprint(weightBuckets[b], "+")
+			if b > 0 && weightBuckets[b-1]-1 > weightBuckets[b] {
+				weights = fmt.Sprint(weightBuckets[b], "-", weightBuckets[b-1]-1)
+			} else if b > 0 {
+				weights = fmt.Sprint(weightBuckets[b])
+			}
+			groups = append(groups, TogoGroup{Title: locale.Text("group_weight", weights), Togos: bucket})
+		}
+	case GroupByExtra:
+		var mandatory, extra TogoList
+		for i := range togos {
+			if togos[i].Extra {
+				extra = extra.Add(&togos[i])
+			} else {
+				mandatory = mandatory.Add(&togos[i])
+			}
+		}
+		if len(mandatory) > 0 {
+			groups = append(groups, TogoGroup{Title: locale.Text("group_mandatory"), Togos: mandatory})
+		}
+		if len(extra) > 0 {
+			groups = append(groups, TogoGroup{Title: locale.Text("group_extra"), Togos: extra})
+		}
+	default:
+		for _, day := range togos.SplitByDay() {
+			first := day[0].Date.StartOfDay()
+			groups = append(groups, TogoGroup{Title: "📅 " + locale.FormatDay(&first), Togos: day})
+		}
+	}
+	return groups
+}
+
+// Subtotal is ProgressMade of a group of togos; a group of just extra togos has nothing to be measured against,
+// so it's measured by itself, as if they were mandatory. %, the listings and the statistics all measure days by this.
+func (togos TogoList) Subtotal() (progress float64, completedInPercent float64, completed uint64, extra uint64, total uint64) {
+	progress, completedInPercent, completed, extra, total = togos.ProgressMade()
+	if total == 0 && extra > 0 {
+		mandatory := append(TogoList{}, togos...)
+		for i := range mandatory {
+			mandatory[i].Extra = false
+		}
+		progress, completedInPercent, completed, extra, total = mandatory.ProgressMade()
+	}
+	return
+}
+
+// Group groups the togos and sorts each group, as the options say
+func (togos TogoList) Group(options ListingOptions, locale Locale) []TogoGroup {
+	groups := togos.GroupBy(options.GroupBy, locale)
+	for i := range groups {
+		groups[i].Togos = append(TogoList{}, groups[i].Togos...).SortBy(options.SortBy)
+	}
+	return groups
+}
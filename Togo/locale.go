@@ -0,0 +1,339 @@
+package ToGo4BotPlus
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type Language string
+
+const (
+	English         Language = "en"
+	Persian         Language = "fa"
+	DefaultLanguage          = English
+	RightToLeftMark          = "\u200f" // keeps the lines of right-to-left texts right aligned, even if they start with latin letters or digits
+)
+
+var Languages = []Language{English, Persian}
+
+type Calendar string
+
+const (
+	Gregorian Calendar = "gregorian"
+	Jalali    Calendar = "jalali"
+)
+
+// Locale is how a chat sees the bot: the language of the texts, and the calendar and format of the dates
+type Locale struct {
+	Language
+	Calendar   Calendar
+	DateFormat DateFormat
+}
+
+var DefaultLocale = Locale{DefaultLanguage, Gregorian, NumericDate}
+
+// the texts of the relative dates, by the number of days from today
+var relativeDays = map[int]string{-1: "yesterday_at", 0: "today_at", 1: "tomorrow_at"}
+
+// messages is the catalog of the texts shown to users; English ones are used when a translation is missing
+var messages = map[string]map[Language]string{
+	// handlers
+	"what":            {English: "What?", Persian: "چی؟"},
+	"need_parameters": {English: "You must provide at least one Parameters!", Persian: "باید دست‌کم یک پارامتر بدهید!"},
+	"need_id":         {English: "You must provide the get identifier!", Persian: "باید شناسه‌ی توگو را بدهید!"},
+	"created":         {English: "%s: DONE!", Persian: "%s: انجام شد!"},
+	"nothing":         {English: "Nothing!", Persian: "هیچی!"},
+	"progress_today": {English: "Today's Progress: %3.2f%% \n%3.2f%% Completed\nStatistics: %d / %d\n",
+		Persian: "پیشرفت امروز: %3.2f%%\n%3.2f%% کامل شده\nآمار: %d / %d\n"},
+	"progress_range": {English: "Progress of %s: %3.2f%% \n%3.2f%% Completed\nStatistics: %d / %d\n",
+		Persian: "پیشرفت %s: %3.2f%%\n%3.2f%% کامل شده\nآمار: %d / %d\n"},
+	"progress_total": {English: "Total Progress: %3.2f%% \n%3.2f%% Completed\nStatistics: %d / %d\n",
+		Persian: "پیشرفت کل: %3.2f%%\n%3.2f%% کامل شده\nآمار: %d / %d\n"},
+	"members":         {English: "Members:", Persian: "اعضا:"},
+	"unassigned":      {English: "unassigned", Persian: "بدون مسئول"},
+	"tick_menu":       {English: "Here are your togos for today:", Persian: "توگوهای امروز شما:"},
+	"nothing_to_tick": {English: "No togos to tick!", Persian: "توگویی برای تیک زدن نیست!"},
+	"remove_today":    {English: "Here are your Today's togos:", Persian: "توگوهای امروز شما:"},
+	"remove_all":      {English: "Here are your ALL togos:", Persian: "همه‌ی توگوهای شما:"},
+	"ticked":          {English: "✅ DONE! Now select the next togo you want to tick ...", Persian: "✅ انجام شد! حالا توگوی بعدی را برای تیک زدن انتخاب کنید ..."},
+	"removed":         {English: "❌ DONE! Now select the next togo you want to REMOVE ...", Persian: "❌ انجام شد! حالا توگوی بعدی را برای حذف انتخاب کنید ..."},
+	"all_removed":     {English: "❌ DONE! All removed.", Persian: "❌ انجام شد! همه حذف شدند."},
+	"shared_by":       {English: "👥 Shared by %s:", Persian: "👥 به اشتراک گذاشته شده توسط %s:"},
+	"view_only":       {English: "👁 view only", Persian: "👁 فقط مشاهده"},
+	"language":        {English: "Language: English", Persian: "زبان: فارسی"},
+	"language_usage":  {English: "Choose the language by: /language  en | fa", Persian: "زبان را این‌طور انتخاب کنید: /language  en | fa"},
+	"gregorian":       {English: "Calendar: Gregorian", Persian: "تقویم: میلادی"},
+	"jalali":          {English: "Calendar: Jalali (Persian)", Persian: "تقویم: شمسی"},
+	"calendar_usage":  {English: "Choose the calendar by: /calendar  jalali | gregorian", Persian: "تقویم را این‌طور انتخاب کنید: /calendar  jalali | gregorian"},
+	"warning":         {English: "warning: %s", Persian: "هشدار: %s"},
+	"seems":           {English: "seems: %s", Persian: "به نظر می‌رسد: %s"},
+	"ics_caption":     {English: "%d togos; open this file with your calendar app.", Persian: "%d توگو؛ این فایل را با برنامه‌ی تقویم خود باز کنید."},
+	"export_caption":  {English: "%d togos; send this file back to the bot to import them again.", Persian: "%d توگو؛ برای وارد کردن دوباره، این فایل را برای ربات بفرستید."},
+	"daily_chart":     {English: "Daily progress of the last %d days; the red line is the %3.0f%% target.", Persian: "پیشرفت روزانه‌ی %d روز گذشته؛ خط قرمز هدف %3.0f%% است."},
+	"weight_chart":    {English: "Average progress per weight in the last %d days.", Persian: "میانگین پیشرفت هر وزن در %d روز گذشته."},
+	// access & limits
+	"welcome":        {English: "Welcome! You can use the bot now.", Persian: "خوش آمدید! حالا می‌توانید از ربات استفاده کنید."},
+	"access_denied":  {English: "This bot is private. Ask an admin for an invite link.", Persian: "این ربات خصوصی است. از یک مدیر لینک دعوت بخواهید."},
+	"banned":         {English: "You are banned!", Persian: "شما مسدود شده‌اید!"},
+	"invite":         {English: "Invite code: %s\nhttps://t.me/%s?start=%s\n(each code can be used once)", Persian: "کد دعوت: %s\nhttps://t.me/%s?start=%s\n(هر کد فقط یک بار قابل استفاده است)"},
+	"commands_limit": {English: "⚠️ Too many commands! The rest of them are ignored; slow down a bit.", Persian: "⚠️ دستورها خیلی زیادند! بقیه‌شان نادیده گرفته شد؛ کمی آهسته‌تر."},
+	"messages_limit": {English: "⚠️ Too many messages! Some of the bot responses are dropped; slow down a bit.", Persian: "⚠️ پیام‌ها خیلی زیادند! برخی از پاسخ‌های ربات فرستاده نشد؛ کمی آهسته‌تر."},
+	// reminders
+	"late_reminder":  {English: "⏰ Late reminder! The bot was down when this togo was due:", Persian: "⏰ یادآوری با تأخیر! ربات هنگام شروع این توگو خاموش بود:"},
+	"done_button":    {English: "✅ Done", Persian: "✅ انجام شد"},
+	"skip_button":    {English: "⏭ Skip", Persian: "⏭ رد کردن"},
+	"snooze_button":  {English: "😴 %dm", Persian: "😴 %d دقیقه"},
+	"tomorrow":       {English: "📅 Tomorrow", Persian: "📅 فردا"},
+	"completed":      {English: "✅ DONE!", Persian: "✅ انجام شد!"},
+	"skipped":        {English: "⏭ Skipped; it's not counted as open, nor rolled over:", Persian: "⏭ رد شد؛ دیگر باز حساب نمی‌شود و به روز بعد منتقل نمی‌شود:"},
+	"snoozed":        {English: "😴 Snoozed; you will be reminded again.", Persian: "😴 به تعویق افتاد؛ دوباره یادآوری می‌شود."},
+	"moved_tomorrow": {English: "📅 Moved to tomorrow.", Persian: "📅 به فردا منتقل شد."},
+	"invalid_snooze": {English: "invalid snooze time", Persian: "زمان تعویق نامعتبر است"},
+	// rollover
+	"rolled_over":  {English: "↪ %d unfinished togos of yesterday are moved to today.", Persian: "↪ %d توگوی ناتمام دیروز به امروز منتقل شد."},
+	"rollover_on":  {English: "↪ Rollover is ON: your unfinished mandatory togos of each day are moved to the same time on the next day, right after midnight.", Persian: "↪ انتقال خودکار روشن است: توگوهای اجباری ناتمام هر روز، بعد از نیمه‌شب به همان ساعت در روز بعد منتقل می‌شوند."},
+	"rollover_off": {English: "Rollover is OFF. Send /rollover  on to move your unfinished mandatory togos to the next day, every night.", Persian: "انتقال خودکار خاموش است. برای انتقال شبانه‌ی توگوهای اجباری ناتمام به روز بعد، /rollover  on را بفرستید."},
+	// togos
+	"togo":        {English: "Togo #%d) %s:\t%s", Persian: "توگو #%d) %s:\t%s"},
+	"weight":      {English: "Weight: %d", Persian: "وزن: %d"},
+	"extra":       {English: "Extra: %s", Persian: "اضافه: %s"},
+	"progress":    {English: "Progress: %d", Persian: "پیشرفت: %d"},
+	"at":          {English: "At: %s, about %.1f minutes", Persian: "زمان: %s، حدود %.1f دقیقه"},
+	"assigned_to": {English: "Assigned to: @%s", Persian: "مسئول: @%s"},
+	"rollovers":   {English: "↪ Rolled over %d times", Persian: "↪ %d بار به روز بعد منتقل شده"},
+	"skip_mark":   {English: "⏭ Skipped", Persian: "⏭ رد شده"},
+	"true":        {English: "true", Persian: "بله"},
+	"false":       {English: "false", Persian: "خیر"},
+	// dates
+	"yesterday_at": {English: "yesterday %02d:%02d", Persian: "دیروز %02d:%02d"},
+	"today_at":     {English: "today %02d:%02d", Persian: "امروز %02d:%02d"},
+	"tomorrow_at":  {English: "tomorrow %02d:%02d", Persian: "فردا %02d:%02d"},
+	// ranges
+	"range_today":       {English: "today", Persian: "امروز"},
+	"range_yesterday":   {English: "yesterday", Persian: "دیروز"},
+	"range_tomorrow":    {English: "tomorrow", Persian: "فردا"},
+	"range_this_week":   {English: "this week", Persian: "این هفته"},
+	"range_last_week":   {English: "last week", Persian: "هفته‌ی گذشته"},
+	"range_next_7_days": {English: "the next 7 days", Persian: "۷ روز آینده"},
+	"range_this_month":  {English: "this month", Persian: "این ماه"},
+	"range_all_days":    {English: "all days", Persian: "همه‌ی روزها"},
+	// groups
+	"group_weight":    {English: "⚖ Weight %s", Persian: "⚖ وزن %s"},
+	"group_mandatory": {English: "📌 Mandatory", Persian: "📌 اجباری"},
+	"group_extra":     {English: "⭐ Extra", Persian: "⭐ اضافه"},
+	// settings
+	"settings":              {English: "⚙ Settings: tap an option to change it, or send /settings  name  value", Persian: "⚙ تنظیمات: برای تغییر هر گزینه رویش بزنید، یا /settings  name  value را بفرستید"},
+	"setting_weight":        {English: "⚖ Default weight: %s", Persian: "⚖ وزن پیش‌فرض: %s"},
+	"setting_date":          {English: "📅 Date format: %s", Persian: "📅 قالب تاریخ: %s"},
+	"setting_completed":     {English: "✅ List completed togos: %s", Persian: "✅ نمایش توگوهای انجام‌شده: %s"},
+	"setting_notifications": {English: "🔔 Notifications: %s", Persian: "🔔 اعلان‌ها: %s"},
+	"setting_separator":     {English: "➖ Separator: %s", Persian: "➖ جداکننده: %s"},
+	// statistics
+	"stats_title":      {English: "Statistics of the last %d days (target: %3.2f%%):", Persian: "آمار %d روز گذشته (هدف: %3.2f%%):"},
+	"streaks":          {English: "Current Streak: %d days\nBest Streak: %d days", Persian: "رکورد فعلی: %d روز\nبهترین رکورد: %d روز"},
+	"weekly_averages":  {English: "Weekly Averages:", Persian: "میانگین‌های هفتگی:"},
+	"monthly_averages": {English: "Monthly Averages:", Persian: "میانگین‌های ماهانه:"},
+	"period_average":   {English: "%s: %3.2f%% (%d active days)", Persian: "%s: %3.2f%% (%d روز فعال)"},
+	"extras_done":      {English: "Extras Done: %d / %d (%3.2f%%)", Persian: "اضافه‌های انجام‌شده: %d / %d (%3.2f%%)"},
+	// import
+	"import_types":     {English: "Only .ics, .csv and .json files can be imported!", Persian: "فقط فایل‌های .ics، .csv و .json وارد می‌شوند!"},
+	"import_too_big":   {English: "This file is too big to import!", Persian: "این فایل برای وارد کردن خیلی بزرگ است!"},
+	"nothing_imported": {English: "Nothing imported: %s", Persian: "چیزی وارد نشد: %s"},
+	"import_report":    {English: "Created: %d\nSkipped as duplicate: %d\nRejected: %d", Persian: "ساخته شد: %d\nتکراری و رد شده: %d\nنپذیرفته: %d"},
+	// shares
+	"need_user":              {English: "You must provide the @username or telegram id!", Persian: "باید @username یا شناسه‌ی تلگرام را بدهید!"},
+	"share_access_view":      {English: "see", Persian: "مشاهده"},
+	"share_access_edit":      {English: "see and edit", Persian: "مشاهده و ویرایش"},
+	"share_scope_all_their":  {English: "all of their togos", Persian: "همه‌ی توگوهایش"},
+	"share_scope_some_their": {English: "%d of their togos", Persian: "%d توگو از توگوهایش"},
+	"share_scope_all_your":   {English: "all of your togos", Persian: "همه‌ی توگوهای شما"},
+	"share_scope_some_your":  {English: "%d of your togos", Persian: "%d توگو از توگوهای شما"},
+	"shared_with_you":        {English: "👥 %s shared %s with you; you can %s them by #.", Persian: "👥 %s %s را با شما به اشتراک گذاشت؛ با # دسترسی %s دارید."},
+	"shared":                 {English: "👥 DONE! %s can %s %s now.", Persian: "👥 انجام شد! %[1]s حالا به %[3]s دسترسی %[2]s دارد."},
+	"unshared":               {English: "👥 %d shares are revoked.", Persian: "👥 %d اشتراک لغو شد."},
+	"nothing_shared":         {English: "Nothing shared yet. Share your togos with: /share  @username  [view | edit]  [togo_id ...]", Persian: "هنوز چیزی به اشتراک گذاشته نشده. توگوهایتان را این‌طور به اشتراک بگذارید: /share  @username  [view | edit]  [togo_id ...]"},
+	"shares_given":           {English: "👥 Shared by you:", Persian: "👥 به اشتراک گذاشته‌ی شما:"},
+	"shares_received":        {English: "👥 Shared with you:", Persian: "👥 به اشتراک گذاشته با شما:"},
+	"share":                  {English: "%s: %s %s", Persian: "%s: %s %s"},
+	"share_view":             {English: "view", Persian: "مشاهده"},
+	"share_edit":             {English: "edit", Persian: "ویرایش"},
+	"share_all":              {English: "all togos", Persian: "همه‌ی توگوها"},
+	"share_togo":             {English: "togo #%d", Persian: "توگو #%d"},
+	// templates
+	"no_templates":             {English: "You have no templates yet. Save one with: /template  save  name  +  title  @  hh:mm  +  next_title  @  hh:mm ...", Persian: "هنوز قالبی ندارید. این‌طور یکی ذخیره کنید: /template  save  name  +  title  @  hh:mm  +  next_title  @  hh:mm ..."},
+	"need_template_name":       {English: "You must provide the name of the template!", Persian: "باید نام قالب را بدهید!"},
+	"template_saved":           {English: "📋 DONE!\n%s", Persian: "📋 انجام شد!\n%s"},
+	"template_deleted":         {English: "📋 %s is deleted.", Persian: "📋 %s حذف شد."},
+	"template_used":            {English: "📋 DONE! %d togos are created from %s.", Persian: "📋 انجام شد! %d توگو از %s ساخته شد."},
+	"template_partly_used":     {English: "%d togos are created, but then: %s", Persian: "%d توگو ساخته شد، اما بعد: %s"},
+	"unknown_template_command": {English: "Unknown template command: %s", Persian: "دستور ناشناخته‌ی قالب: %s"},
+	"template":                 {English: "📋 %s (%d togos):", Persian: "📋 %s (%d توگو):"},
+	"template_item":            {English: "%s  %s, weight %d", Persian: "%s  %s، وزن %d"},
+	"template_extra":           {English: ", extra", Persian: "، اضافه"},
+	"template_minutes":         {English: ", %.0f minutes", Persian: "، %.0f دقیقه"},
+}
+
+// ---------------------- Language Receivers --------------------------------
+// ParseLanguage reads language codes like fa or fa-IR (as telegram sends them) and names like persian or فارسی
+func ParseLanguage(code string) (Language, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	switch {
+	case strings.HasPrefix(code, "fa"), code == "persian", code == "farsi", code == "فارسی":
+		return Persian, true
+	case strings.HasPrefix(code, "en"), code == "english":
+		return English, true
+	}
+	return DefaultLanguage, false
+}
+
+func (language Language) IsRightToLeft() bool {
+	return language == Persian
+}
+
+// Text returns the message of the key in the language, formatted with the args if there's any
+func (language Language) Text(key string, args ...interface{}) string {
+	translations, ok := messages[key]
+	if !ok {
+		return key
+	}
+	text, ok := translations[language]
+	if !ok {
+		text = translations[DefaultLanguage]
+	}
+	if len(args) > 0 {
+		text = fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Align marks each line of the text as right-to-left, in right-to-left languages
+func (language Language) Align(text string) string {
+	if !language.IsRightToLeft() {
+		return text
+	}
+	return RightToLeftMark + strings.ReplaceAll(text, "\n", "\n"+RightToLeftMark)
+}
+
+// NormalizeDigits replaces persian and arabic digits with latin ones, so numbers can be typed with any keyboard
+func NormalizeDigits(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '۰' && r <= '۹' {
+			return '0' + (r - '۰')
+		} else if r >= '٠' && r <= '٩' {
+			return '0' + (r - '٠')
+		}
+		return r
+	}, text)
+}
+
+// DefaultCalendar is the calendar of the chats that haven't chosen one
+func (language Language) DefaultCalendar() Calendar {
+	if language == Persian {
+		return Jalali
+	}
+	return Gregorian
+}
+
+// ---------------------- Calendar Receivers --------------------------------
+// ParseCalendar reads calendar names like jalali, shamsi or شمسی and gregorian, miladi or میلادی
+func ParseCalendar(name string) (Calendar, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "jalali", "shamsi", "persian", "solar", "شمسی", "جلالی":
+		return Jalali, true
+	case "gregorian", "miladi", "christian", "میلادی":
+		return Gregorian, true
+	}
+	return Gregorian, false
+}
+
+// FormatDate is Date.Get in the calendar and date format of the locale
+func (locale Locale) FormatDate(date *Date) string {
+	switch locale.DateFormat {
+	case RelativeDate:
+		days := int(math.Round(date.StartOfDay().Sub(Today().StartOfDay().Time).Hours() / 24))
+		if key, ok := relativeDays[days]; ok {
+			return locale.Text(key, date.Hour(), date.Minute())
+		}
+		fallthrough
+	case PaddedDate:
+		return fmt.Sprintf("%s %02d:%02d", locale.FormatDay(date), date.Hour(), date.Minute())
+	}
+	if locale.Calendar == Jalali {
+		return date.JalaliGet()
+	}
+	return date.Get()
+}
+
+// FormatDay is Date.Short in the calendar and date format of the locale
+func (locale Locale) FormatDay(date *Date) string {
+	if locale.DateFormat == NumericDate {
+		if locale.Calendar == Jalali {
+			return date.JalaliShort()
+		}
+		return date.Short()
+	}
+	year, month, day := date.Year(), int(date.Month()), date.Day()
+	if locale.Calendar == Jalali {
+		year, month, day = ToJalali(date.Time)
+	}
+	return fmt.Sprintf("%04d/%02d/%02d", year, month, day)
+}
+
+// FormatRange is the name of the range, or its first and last days if it has no name
+func (locale Locale) FormatRange(within Range) string {
+	if within.Name != "" {
+		return locale.Text("range_" + within.Name)
+	}
+	last := within.To.AddDays(-1)
+	return locale.FormatDay(&within.From) + " ~ " + locale.FormatDay(&last)
+}
+
+// ---------------------- Language Functions --------------------------------
+// LoadLanguage returns the language chosen by the chat, and false if it hasn't chosen any yet
+func LoadLanguage(chatId int64) (Language, bool) {
+	load, err := statement("SELECT COALESCE(language, '') FROM users WHERE id=?")
+	if err != nil {
+		return DefaultLanguage, false
+	}
+	var code string
+	if err := load.QueryRow(chatId).Scan(&code); err != nil || code == "" {
+		return DefaultLanguage, false
+	}
+	return ParseLanguage(code)
+}
+
+// SetLanguage stores the language of the chat; groups are stored in users table too, without being seen as users
+func SetLanguage(chatId int64, language Language) error {
+	set, err := statement(`INSERT INTO users (id, first_seen, last_seen, language) VALUES (?, NULL, NULL, ?)
+		ON CONFLICT(id) DO UPDATE SET language=excluded.language`)
+	if err != nil {
+		return err
+	}
+	_, err = set.Exec(chatId, string(language))
+	return err
+}
+
+// LoadCalendar returns the calendar chosen by the chat, and false if it hasn't chosen any yet
+func LoadCalendar(chatId int64) (Calendar, bool) {
+	load, err := statement("SELECT COALESCE(calendar, '') FROM users WHERE id=?")
+	if err != nil {
+		return Gregorian, false
+	}
+	var name string
+	if err := load.QueryRow(chatId).Scan(&name); err != nil || name == "" {
+		return Gregorian, false
+	}
+	return ParseCalendar(name)
+}
+
+// SetCalendar stores the calendar of the chat, the same way as SetLanguage
+func SetCalendar(chatId int64, calendar Calendar) error {
+	set, err := statement(`INSERT INTO users (id, first_seen, last_seen, calendar) VALUES (?, NULL, NULL, ?)
+		ON CONFLICT(id) DO UPDATE SET calendar=excluded.calendar`)
+	if err != nil {
+		return err
+	}
+	_, err = set.Exec(chatId, string(calendar))
+	return err
+}
@@ -0,0 +1,115 @@
+package ToGo4BotPlus
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RangeSeparator = ".." // from..to
+	FirstWeekday   = time.Saturday
+)
+
+// ---------------------- Range Struct & Receivers --------------------------------
+// Range is a span of whole days, that togos are loaded in: From is included and To is not;
+// the zero Range (AllDays) has no bounds at all.
+type Range struct {
+	From Date
+	To   Date
+	Name string // the catalog key of named ranges, like this_week; empty for the explicit ones
+}
+
+func AllDays() Range {
+	return Range{Name: "all_days"}
+}
+
+// DaysRange is the days days starting at the day of from
+func DaysRange(from Date, days int, name string) Range {
+	start := from.StartOfDay()
+	return Range{From: start, To: start.AddDays(days), Name: name}
+}
+
+func TodayRange() Range {
+	return DaysRange(Today(), 1, "today")
+}
+
+func (r Range) IsAll() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+func (r Range) Contains(date Date) bool {
+	return r.IsAll() || (!date.Before(r.From.Time) && date.Before(r.To.Time))
+}
+
+// Days is the number of days in the range; 0 for AllDays
+func (r Range) Days() int {
+	if r.IsAll() {
+		return 0
+	}
+	return int(r.To.Sub(r.From.Time).Hours()/24 + 0.5)
+}
+
+// ParseRange reads ranges like today, yesterday, tomorrow, week, last-week, next-7, month, all, or from..to,
+// where from and to are days from today or absolute dates (see ParseDay) and to is included; weeks start on
+// FirstWeekday, and months are months of the calendar.
+func ParseRange(term string, calendar Calendar) (Range, error) {
+	today := Today().StartOfDay()
+	switch strings.ToLower(term) {
+	case "today":
+		return TodayRange(), nil
+	case "yesterday":
+		return DaysRange(today.AddDays(-1), 1, "yesterday"), nil
+	case "tomorrow":
+		return DaysRange(today.AddDays(1), 1, "tomorrow"), nil
+	case "week", "this-week":
+		start := today.AddDays(-((int(today.Weekday()) - int(FirstWeekday) + 7) % 7))
+		return DaysRange(start, 7, "this_week"), nil
+	case "last-week":
+		start := today.AddDays(-((int(today.Weekday())-int(FirstWeekday)+7)%7 + 7))
+		return DaysRange(start, 7, "last_week"), nil
+	case "next-7", "+7":
+		return DaysRange(today, 7, "next_7_days"), nil
+	case "month", "this-month":
+		return monthRange(today, calendar), nil
+	case "a", "all", "+a":
+		return AllDays(), nil
+	}
+	bounds := strings.Split(term, RangeSeparator)
+	if len(bounds) != 2 {
+		return Range{}, errors.New("range must be like today, yesterday, week, last-week, next-7, month, all or from..to")
+	}
+	from, err := parseRangeDay(bounds[0], today)
+	if err != nil {
+		return Range{}, err
+	}
+	to, err := parseRangeDay(bounds[1], today)
+	if err != nil {
+		return Range{}, err
+	} else if to.Before(from.Time) {
+		return Range{}, errors.New("the end of the range is before its start")
+	}
+	return Range{From: from, To: to.AddDays(1)}, nil
+}
+
+// parseRangeDay reads a day as the number of days from today, or as an absolute date
+func parseRangeDay(day string, today Date) (Date, error) {
+	if days, err := strconv.Atoi(day); err == nil {
+		return today.AddDays(days), nil
+	}
+	date, err := ParseDay(day, today.Location())
+	return Date{date}, err
+}
+
+// monthRange is the month of the day, in the calendar
+func monthRange(day Date, calendar Calendar) Range {
+	if calendar == Jalali {
+		jy, jm, _ := ToJalali(day.Time)
+		if start, err := FromJalali(jy, jm, 1, day.Location()); err == nil {
+			return DaysRange(Date{start}, JalaliMonthLength(jy, jm), "this_month")
+		}
+	}
+	start := Date{time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())}
+	return Range{From: start, To: Date{start.AddDate(0, 1, 0)}, Name: "this_month"}
+}
@@ -0,0 +1,45 @@
+package ToGo4BotPlus
+
+import (
+	"time"
+)
+
+// remind_at is stored as unix time, so that a togo moved to another time is reminded again
+const CREATE_REMINDERS_TABLE_QUERY string = `CREATE TABLE IF NOT EXISTS sent_reminders (togo_id INTEGER NOT NULL, remind_at BIGINT NOT NULL,
+	sent_at DATETIME, PRIMARY KEY (togo_id, remind_at))`
+
+// ---------------------- Sent Reminders --------------------------------
+// ClaimReminder records the reminder of the togo at the time as sent; it returns false if it's already sent,
+// so a reminder is never sent twice, even by the catch-up after a restart.
+func ClaimReminder(togoId uint64, at time.Time) (bool, error) {
+	claim, err := statement("INSERT OR IGNORE INTO sent_reminders (togo_id, remind_at, sent_at) VALUES (?, ?, ?)")
+	if err != nil {
+		return false, err
+	}
+	result, err := claim.Exec(togoId, at.Unix(), time.Now())
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+// ReleaseReminder removes the record of the reminder, claimed by ClaimReminder but not sent, so it can be claimed again
+func ReleaseReminder(togoId uint64, at time.Time) error {
+	release, err := statement("DELETE FROM sent_reminders WHERE togo_id=? AND remind_at=?")
+	if err != nil {
+		return err
+	}
+	_, err = release.Exec(togoId, at.Unix())
+	return err
+}
+
+// ForgetRemindersBefore removes the records of the reminders due before the time, that can't be sent again anyway
+func ForgetRemindersBefore(before time.Time) error {
+	forget, err := statement("DELETE FROM sent_reminders WHERE remind_at < ?")
+	if err != nil {
+		return err
+	}
+	_, err = forget.Exec(before.Unix())
+	return err
+}
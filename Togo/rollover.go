@@ -0,0 +1,73 @@
+package ToGo4BotPlus
+
+import (
+	"database/sql"
+)
+
+// ---------------------- Rollover Functions --------------------------------
+// SetRollover turns the nightly rollover of the chat's unfinished togos on or off; stored the same way as SetLanguage,
+// so that it works in groups too
+func SetRollover(chatId int64, enabled bool) error {
+	set, err := statement(`INSERT INTO users (id, first_seen, last_seen, rollover) VALUES (?, NULL, NULL, ?)
+		ON CONFLICT(id) DO UPDATE SET rollover=excluded.rollover`)
+	if err != nil {
+		return err
+	}
+	_, err = set.Exec(chatId, enabled)
+	return err
+}
+
+// IsRolloverEnabled tells if the chat has turned the rollover on; chats that have never set it have it off
+func IsRolloverEnabled(chatId int64) (enabled bool, err error) {
+	load, err := statement("SELECT COALESCE(rollover, 0) FROM users WHERE id=?")
+	if err != nil {
+		return
+	}
+	if err = load.QueryRow(chatId).Scan(&enabled); err == sql.ErrNoRows {
+		return false, nil
+	}
+	return
+}
+
+// Rollover moves the unfinished, mandatory togos of the day, of the users who turned it on, to the same time
+// on the next day, and counts it in their rollovers; it returns the moved togos.
+func Rollover(day Date) (moved TogoList, err error) {
+	db, err := getDatabase()
+	if err != nil {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	from := day.StartOfDay()
+	rows, err := tx.Query(SELECT_TOGOS_QUERY+` WHERE owner_id IN (SELECT id FROM users WHERE rollover=1) AND progress < 100 AND extra=0 AND skipped=0
+		AND datetime(date) >= datetime(?) AND datetime(date) < datetime(?)`, from.Time, from.AddDays(1).Time)
+	if err != nil {
+		return
+	}
+	moved = make(TogoList, 0)
+	for rows.Next() {
+		if togo, e := scanTogo(rows); e == nil {
+			moved = moved.Add(&togo)
+		}
+	}
+	rows.Close()
+
+	for i := range moved {
+		moved[i].Date = moved[i].Date.AddDays(1)
+		moved[i].Rollovers++
+		if _, err = tx.Exec("UPDATE togos SET date=?, rollovers=? WHERE id=?", moved[i].Date.Time, moved[i].Rollovers, moved[i].Id); err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	for i := range moved {
+		notifyChange(&moved[i], false)
+	}
+	return
+}
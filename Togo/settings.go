@@ -0,0 +1,211 @@
+package ToGo4BotPlus
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const CREATE_SETTINGS_TABLE_QUERY string = `CREATE TABLE IF NOT EXISTS user_settings (user_id BIGINT PRIMARY KEY, default_weight INTEGER DEFAULT 1,
+	date_format VARCHAR(16) DEFAULT 'numeric', list_completed INTEGER DEFAULT 1, notifications INTEGER DEFAULT 1,
+	separator VARCHAR(16) DEFAULT 'dashes', updated_at DATETIME)`
+
+type DateFormat string
+
+const (
+	NumericDate  DateFormat = "numeric"  // 2024-10-19	9:30
+	PaddedDate   DateFormat = "padded"   // 2024/10/19 09:30
+	RelativeDate DateFormat = "relative" // today 09:30, and padded for the days other than yesterday, today and tomorrow
+)
+
+var DateFormats = []DateFormat{NumericDate, PaddedDate, RelativeDate}
+
+type SeparatorStyle string
+
+const (
+	Dashes SeparatorStyle = "dashes"
+	Line   SeparatorStyle = "line"
+	Dots   SeparatorStyle = "dots"
+	Blank  SeparatorStyle = "blank"
+)
+
+var SeparatorStyles = []SeparatorStyle{Dashes, Line, Dots, Blank}
+
+var separatorLines = map[SeparatorStyle]string{
+	Dashes: "- - - - - - - - - - - - - - - - - - - - - - ",
+	Line:   "──────────────────",
+	Dots:   "• • • • • • • • • • • •",
+	Blank:  "",
+}
+
+// the names of the settings, as used in the /settings menu and command
+const (
+	WeightSetting        = "weight"
+	DateFormatSetting    = "date"
+	CompletedSetting     = "completed"
+	NotificationsSetting = "notifications"
+	SeparatorSetting     = "separator"
+)
+
+var SettingNames = []string{WeightSetting, DateFormatSetting, CompletedSetting, NotificationsSetting, SeparatorSetting}
+
+const MaximumDefaultWeight = 5 // the menu button cycles the default weight from 1 to this; bigger ones can be typed
+
+// ---------------------- Settings Struct & Receivers --------------------------------
+// Settings are the options of a chat; chats that have never changed them get DefaultSettings
+type Settings struct {
+	UserId        int64
+	DefaultWeight uint16
+	DateFormat    DateFormat
+	ListCompleted bool // whether # lists the completed togos too
+	Notifications bool // reminders and the other messages the bot sends by itself
+	Separator     SeparatorStyle
+}
+
+var DefaultSettings = Settings{DefaultWeight: 1, DateFormat: NumericDate, ListCompleted: true, Notifications: true, Separator: Dashes}
+
+func (style SeparatorStyle) Line() string {
+	return separatorLines[style]
+}
+
+// Value is the current value of the setting, as text
+func (settings *Settings) Value(name string) string {
+	switch name {
+	case WeightSetting:
+		return fmt.Sprint(settings.DefaultWeight)
+	case DateFormatSetting:
+		return string(settings.DateFormat)
+	case CompletedSetting:
+		return fmt.Sprint(settings.ListCompleted)
+	case NotificationsSetting:
+		return fmt.Sprint(settings.Notifications)
+	case SeparatorSetting:
+		return string(settings.Separator)
+	}
+	return ""
+}
+
+// Set changes the setting to the value, as typed by the user: on/off, true/false, a weight or the name of an option
+func (settings *Settings) Set(name string, value string) error {
+	switch name {
+	case WeightSetting:
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight <= 0 || weight > 0xFFFF {
+			return errors.New("default weight must be a positive integer")
+		}
+		settings.DefaultWeight = uint16(weight)
+	case DateFormatSetting:
+		for _, format := range DateFormats {
+			if string(format) == value {
+				settings.DateFormat = format
+				return nil
+			}
+		}
+		return fmt.Errorf("date format must be one of %v", DateFormats)
+	case CompletedSetting, NotificationsSetting:
+		enabled, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		if name == CompletedSetting {
+			settings.ListCompleted = enabled
+		} else {
+			settings.Notifications = enabled
+		}
+	case SeparatorSetting:
+		for _, style := range SeparatorStyles {
+			if string(style) == value {
+				settings.Separator = style
+				return nil
+			}
+		}
+		return fmt.Errorf("separator must be one of %v", SeparatorStyles)
+	default:
+		return errors.New("there is no setting named " + name)
+	}
+	return nil
+}
+
+// Next changes the setting to its next option, the way the buttons of the /settings menu do
+func (settings *Settings) Next(name string) error {
+	switch name {
+	case WeightSetting:
+		settings.DefaultWeight = settings.DefaultWeight%MaximumDefaultWeight + 1
+	case DateFormatSetting:
+		next := 0
+		for i := range DateFormats {
+			if DateFormats[i] == settings.DateFormat {
+				next = (i + 1) % len(DateFormats)
+			}
+		}
+		settings.DateFormat = DateFormats[next]
+	case CompletedSetting:
+		settings.ListCompleted = !settings.ListCompleted
+	case NotificationsSetting:
+		settings.Notifications = !settings.Notifications
+	case SeparatorSetting:
+		next := 0
+		for i := range SeparatorStyles {
+			if SeparatorStyles[i] == settings.Separator {
+				next = (i + 1) % len(SeparatorStyles)
+			}
+		}
+		settings.Separator = SeparatorStyles[next]
+	default:
+		return errors.New("there is no setting named " + name)
+	}
+	return nil
+}
+
+func (settings *Settings) Save() error {
+	save, err := statement(`INSERT INTO user_settings (user_id, default_weight, date_format, list_completed, notifications, separator, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT(user_id) DO UPDATE SET default_weight=excluded.default_weight, date_format=excluded.date_format,
+		list_completed=excluded.list_completed, notifications=excluded.notifications, separator=excluded.separator, updated_at=excluded.updated_at`)
+	if err != nil {
+		return err
+	}
+	_, err = save.Exec(settings.UserId, settings.DefaultWeight, string(settings.DateFormat), settings.ListCompleted,
+		settings.Notifications, string(settings.Separator), time.Now())
+	return err
+}
+
+// ---------------------- Settings Functions --------------------------------
+// LoadSettings loads the settings of the chat; DefaultSettings are returned on errors too, so the bot keeps working
+func LoadSettings(userId int64) (Settings, error) {
+	settings := DefaultSettings
+	settings.UserId = userId
+	load, err := statement("SELECT default_weight, date_format, list_completed, notifications, separator FROM user_settings WHERE user_id=?")
+	if err != nil {
+		return settings, err
+	}
+	var dateFormat, separator string
+	loaded := settings
+	if err := load.QueryRow(userId).Scan(&loaded.DefaultWeight, &dateFormat, &loaded.ListCompleted, &loaded.Notifications, &separator); err == sql.ErrNoRows {
+		return settings, nil
+	} else if err != nil {
+		return settings, err
+	}
+	// unknown options (of older or newer versions) fall back to the defaults
+	if loaded.DefaultWeight == 0 {
+		loaded.DefaultWeight = DefaultSettings.DefaultWeight
+	}
+	if loaded.Set(DateFormatSetting, dateFormat) != nil {
+		loaded.DateFormat = DefaultSettings.DateFormat
+	}
+	if loaded.Set(SeparatorSetting, separator) != nil {
+		loaded.Separator = DefaultSettings.Separator
+	}
+	return loaded, nil
+}
+
+func parseSwitch(value string) (bool, error) {
+	switch value {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, errors.New("value must be on or off")
+}
@@ -0,0 +1,266 @@
+package ToGo4BotPlus
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// togo_id 0 means all the togos of the owner, including the ones created later
+const CREATE_SHARES_TABLE_QUERY string = `CREATE TABLE IF NOT EXISTS shares (owner_id BIGINT NOT NULL, grantee_id BIGINT NOT NULL,
+	togo_id INTEGER NOT NULL DEFAULT 0, can_edit INTEGER DEFAULT 0, created_at DATETIME, PRIMARY KEY (owner_id, grantee_id, togo_id))`
+
+// ---------------------- Share Structs & Receivers --------------------------------
+type Share struct {
+	OwnerId   int64
+	GranteeId int64
+	TogoId    uint64 // 0 means all
+	CanEdit   bool
+	Name      string // name of the other side of the share
+}
+
+// SharedList is the togos of an owner that are shared with a grantee
+type SharedList struct {
+	OwnerId   int64
+	OwnerName string
+	Togos     TogoList
+	Editable  map[uint64]bool
+}
+
+func (share *Share) ToString() string {
+	return share.Localize(DefaultLocale)
+}
+
+func (share *Share) Localize(locale Locale) string {
+	access, scope := locale.Text("share_view"), locale.Text("share_all")
+	if share.CanEdit {
+		access = locale.Text("share_edit")
+	}
+	if share.TogoId != 0 {
+		scope = locale.Text("share_togo", share.TogoId)
+	}
+	return locale.Text("share", share.Name, access, scope)
+}
+
+// ---------------------- Share Functions --------------------------------
+// FindUser finds the id of a user who has used the bot, by @username or by telegram id
+func FindUser(user string) (int64, error) {
+	var id int64
+	if _, err := fmt.Sscan(user, &id); err == nil && !strings.HasPrefix(user, "@") {
+		return id, nil
+	}
+	find, err := statement("SELECT id FROM users WHERE username=? COLLATE NOCASE")
+	if err != nil {
+		return 0, err
+	}
+	if err = find.QueryRow(strings.TrimPrefix(user, "@")).Scan(&id); err == sql.ErrNoRows {
+		return 0, errors.New("there is no user " + user + "; they must start the bot first")
+	}
+	return id, err
+}
+
+// userName is how a user is shown to others: first name, @username or at least the id
+func userName(firstName string, username string, id int64) string {
+	if firstName != "" {
+		return firstName
+	} else if username != "" {
+		return "@" + username
+	}
+	return fmt.Sprint(id)
+}
+
+// ShareTogos grants the grantee view or edit access to all the togos of the owner, or just the ones in togoIds
+func ShareTogos(ownerId int64, granteeId int64, togoIds []uint64, canEdit bool) error {
+	if ownerId == granteeId {
+		return errors.New("you can not share your togos with yourself")
+	}
+	db, err := getDatabase()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	owned, err := txStatement(tx, "SELECT COUNT(*) FROM togos WHERE id=? AND owner_id=?")
+	if err != nil {
+		return err
+	}
+	share, err := txStatement(tx, "INSERT OR REPLACE INTO shares (owner_id, grantee_id, togo_id, can_edit, created_at) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	if len(togoIds) == 0 {
+		togoIds = []uint64{0}
+	}
+	for _, togoId := range togoIds {
+		if togoId != 0 {
+			var count int
+			if err := owned.QueryRow(togoId, ownerId).Scan(&count); err != nil {
+				return err
+			} else if count == 0 {
+				return fmt.Errorf("there is no togo with id %d", togoId)
+			}
+		}
+		if _, err := share.Exec(ownerId, granteeId, togoId, canEdit, time.Now()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UnshareTogos revokes the shares of the owner with the grantee; all of them if togoIds is empty
+func UnshareTogos(ownerId int64, granteeId int64, togoIds []uint64) (int64, error) {
+	var revoked int64
+	if len(togoIds) == 0 {
+		unshareAll, err := statement("DELETE FROM shares WHERE owner_id=? AND grantee_id=?")
+		if err != nil {
+			return 0, err
+		}
+		result, err := unshareAll.Exec(ownerId, granteeId)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+	unshare, err := statement("DELETE FROM shares WHERE owner_id=? AND grantee_id=? AND togo_id=?")
+	if err != nil {
+		return 0, err
+	}
+	for _, togoId := range togoIds {
+		result, err := unshare.Exec(ownerId, granteeId, togoId)
+		if err != nil {
+			return revoked, err
+		}
+		count, _ := result.RowsAffected()
+		revoked += count
+	}
+	return revoked, nil
+}
+
+// LoadShares returns the shares that the user has given to others, and the ones others have given to them
+func LoadShares(userId int64) (given []Share, received []Share, err error) {
+	load, err := statement(`SELECT owner_id, grantee_id, togo_id, can_edit, COALESCE(users.first_name, ''), COALESCE(users.username, '')
+		FROM shares LEFT JOIN users ON users.id=(CASE WHEN owner_id=? THEN grantee_id ELSE owner_id END)
+		WHERE owner_id=? OR grantee_id=? ORDER BY created_at`)
+	if err != nil {
+		return
+	}
+	rows, err := load.Query(userId, userId, userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var share Share
+		var firstName, username string
+		if err = rows.Scan(&share.OwnerId, &share.GranteeId, &share.TogoId, &share.CanEdit, &firstName, &username); err != nil {
+			return
+		}
+		if share.OwnerId == userId {
+			share.Name = userName(firstName, username, share.GranteeId)
+			given = append(given, share)
+		} else {
+			share.Name = userName(firstName, username, share.OwnerId)
+			received = append(received, share)
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+// LoadSharedWith loads the togos in the range that are shared with the grantee, grouped by their owners
+func LoadSharedWith(granteeId int64, within Range) ([]SharedList, error) {
+	conditions, args := "shares.grantee_id=?", []interface{}{granteeId}
+	if !within.IsAll() {
+		// compared in UTC, as Load does
+		conditions += " AND datetime(date) >= datetime(?) AND datetime(date) < datetime(?)"
+		args = append(args, within.From.Time, within.To.Time)
+	}
+	load, err := statement(`SELECT togos.id, togos.owner_id, title, description, weight, extra, progress, date, duration, rollovers, assignee, skipped,
+		MAX(shares.can_edit), COALESCE(users.first_name, ''), COALESCE(users.username, '')
+		FROM togos JOIN shares ON shares.owner_id=togos.owner_id AND (shares.togo_id=0 OR shares.togo_id=togos.id)
+		LEFT JOIN users ON users.id=togos.owner_id WHERE ` + conditions + ` GROUP BY togos.id ORDER BY togos.owner_id, date`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := load.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lists := make([]SharedList, 0)
+	for rows.Next() {
+		var togo Togo
+		var date time.Time
+		var canEdit bool
+		var firstName, username string
+		if err := rows.Scan(&togo.Id, &togo.OwnerId, &togo.Title, &togo.Description, &togo.Weight, &togo.Extra, &togo.Progress,
+			&date, &togo.Duration, &togo.Rollovers, &togo.Assignee, &togo.Skipped, &canEdit, &firstName, &username); err != nil {
+			return nil, err
+		}
+		togo.Date = Date{date}.ToLocal()
+		togo.Duration *= time.Minute
+		if count := len(lists); count == 0 || lists[count-1].OwnerId != togo.OwnerId {
+			lists = append(lists, SharedList{OwnerId: togo.OwnerId, OwnerName: userName(firstName, username, togo.OwnerId),
+				Togos: make(TogoList, 0), Editable: make(map[uint64]bool)})
+		}
+		list := &lists[len(lists)-1]
+		list.Togos = list.Togos.Add(&togo)
+		list.Editable[togo.Id] = canEdit
+	}
+	return lists, rows.Err()
+}
+
+// LoadSharedTogo loads a togo that is shared with the grantee, and tells if the grantee can edit it
+func LoadSharedTogo(granteeId int64, togoId uint64) (*Togo, bool, error) {
+	lists, err := LoadSharedWith(granteeId, AllDays())
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range lists {
+		if togo, err := lists[i].Togos.Get(togoId); err == nil {
+			return togo, lists[i].Editable[togoId], nil
+		}
+	}
+	return nil, false, errors.New("there is no togo with this Id")
+}
+
+// UpdateBy updates the togo, if the editor is its owner or has the edit access to it
+func (togo *Togo) UpdateBy(editorId int64) error {
+	if editorId != togo.OwnerId {
+		if _, canEdit, err := LoadSharedTogo(editorId, togo.Id); err != nil {
+			return err
+		} else if !canEdit {
+			return errors.New("you can only view this togo")
+		}
+	}
+	return togo.Update(togo.OwnerId)
+}
+
+// UpdateShared is like TogoList.Update, for a togo that is shared with the editor: terms are  id  [fields to update]
+func UpdateShared(editorId int64, terms []string, locale Locale) (string, error) {
+	var id uint64
+	if _, err := fmt.Sscan(terms[0], &id); err != nil {
+		return "", err
+	}
+	togo, canEdit, err := LoadSharedTogo(editorId, id)
+	if err != nil {
+		return "", err
+	}
+	if len(terms) > 1 && !isCommand(terms[1]) {
+		if !canEdit {
+			return "", errors.New("you can only view this togo")
+		}
+		if err := togo.setFields(terms); err != nil {
+			return "", err
+		}
+		if err := togo.UpdateBy(editorId); err != nil {
+			return "", err
+		}
+	}
+	return togo.Localize(locale), nil
+}
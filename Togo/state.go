@@ -0,0 +1,30 @@
+package ToGo4BotPlus
+
+import (
+	"database/sql"
+)
+
+const CREATE_STATE_TABLE_QUERY string = `CREATE TABLE IF NOT EXISTS bot_state (key VARCHAR(64) PRIMARY KEY, value TEXT)`
+
+// ---------------------- Bot State Functions --------------------------------
+// LoadState returns the stored value of the key, or an empty string if it's not stored yet
+func LoadState(key string) (string, error) {
+	load, err := statement("SELECT value FROM bot_state WHERE key=?")
+	if err != nil {
+		return "", err
+	}
+	var value string
+	if err = load.QueryRow(key).Scan(&value); err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func SaveState(key string, value string) error {
+	save, err := statement("INSERT OR REPLACE INTO bot_state (key, value) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	_, err = save.Exec(key, value)
+	return err
+}
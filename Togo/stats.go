@@ -0,0 +1,182 @@
+package ToGo4BotPlus
+
+import (
+	"time"
+)
+
+const (
+	NumberOfWeeksInTrend  = 4
+	NumberOfMonthsInTrend = 3
+)
+
+// ---------------------- Statistics Structs --------------------------------
+type DayProgress struct {
+	Day       Date
+	Progress  float64
+	Completed uint64
+	Extra     uint64
+	Total     uint64
+}
+
+type PeriodProgress struct {
+	From       Date
+	To         Date
+	Average    float64
+	ActiveDays int // days that had at least one togo
+}
+
+type Statistics struct {
+	Days          []DayProgress // the last N days, oldest first
+	Target        float64
+	CurrentStreak int
+	BestStreak    int
+	Weeks         []PeriodProgress // newest first
+	Months        []PeriodProgress // newest first
+	ExtrasDone    uint64
+	ExtrasTotal   uint64
+}
+
+// ---------------------- Date Helpers --------------------------------
+func (date Date) StartOfDay() Date {
+	local := date.ToLocal()
+	return Date{time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())}
+}
+
+func (date Date) AddDays(days int) Date {
+	return Date{date.AddDate(0, 0, days)}
+}
+
+// ---------------------- Statistics Receivers --------------------------------
+func (stats *Statistics) ExtrasRatio() float64 {
+	if stats.ExtrasTotal == 0 {
+		return 0
+	}
+	return 100 * float64(stats.ExtrasDone) / float64(stats.ExtrasTotal)
+}
+
+// Statistics calculates the per day progress of the last `days` days, streaks of days with
+// progress >= target and weekly/monthly averages. togos must contain all the togos of the user.
+func (togos TogoList) Statistics(days int, target float64) (stats Statistics) {
+	stats.Target = target
+	today := Today().StartOfDay()
+	perDay := togos.GroupByDay()
+	progressOf := func(day Date) (DayProgress, bool) {
+		dayTogos, ok := perDay[day.Short()]
+		result := DayProgress{Day: day}
+		if ok {
+			result.Progress, _, result.Completed, result.Extra, result.Total = dayTogos.Subtotal() // the way % measures the day
+		}
+		return result, ok
+	}
+
+	// streaks are calculated on the whole history of the user
+	first := today
+	for i := range togos {
+		if day := togos[i].Date.StartOfDay(); day.Before(first.Time) {
+			first = day
+		}
+	}
+	run := 0
+	for day := first; !day.After(today.Time); day = day.AddDays(1) {
+		if progress, _ := progressOf(day); progress.Progress >= target {
+			run++
+			if run > stats.BestStreak {
+				stats.BestStreak = run
+			}
+		} else if day.Short() != today.Short() {
+			// today is not over yet, so not reaching the target (yet) doesn't break the streak
+			run = 0
+		}
+	}
+	stats.CurrentStreak = run
+
+	if days > 0 {
+		stats.Days = make([]DayProgress, 0, days)
+		from := today.AddDays(1 - days)
+		for day := from; !day.After(today.Time); day = day.AddDays(1) {
+			progress, _ := progressOf(day)
+			stats.Days = append(stats.Days, progress)
+			for _, togo := range perDay[day.Short()] {
+				if togo.Extra {
+					stats.ExtrasTotal++
+					if togo.Progress >= 100 {
+						stats.ExtrasDone++
+					}
+				}
+			}
+		}
+	}
+
+	average := func(from Date, to Date) PeriodProgress {
+		period := PeriodProgress{From: from, To: to}
+		for day := from; !day.After(to.Time); day = day.AddDays(1) {
+			if progress, ok := progressOf(day); ok {
+				period.Average += progress.Progress
+				period.ActiveDays++
+			}
+		}
+		if period.ActiveDays > 0 {
+			period.Average /= float64(period.ActiveDays)
+		}
+		return period
+	}
+	for week := 0; week < NumberOfWeeksInTrend; week++ {
+		to := today.AddDays(-7 * week)
+		stats.Weeks = append(stats.Weeks, average(to.AddDays(-6), to))
+	}
+	monthStart := Date{time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())}
+	for month := 0; month < NumberOfMonthsInTrend; month++ {
+		from := Date{monthStart.AddDate(0, -month, 0)}
+		to := Date{from.AddDate(0, 1, -1)}
+		if to.After(today.Time) {
+			to = today
+		}
+		stats.Months = append(stats.Months, average(from, to))
+	}
+	return
+}
+
+// Between returns the togos starting in [from, to)
+func (togos TogoList) Between(from Date, to Date) (result TogoList) {
+	result = make(TogoList, 0)
+	for i := range togos {
+		if !togos[i].Date.Before(from.Time) && togos[i].Date.Before(to.Time) {
+			result = result.Add(&togos[i])
+		}
+	}
+	return
+}
+
+// GroupByAssignee groups the togos by the username they're assigned to; unassigned togos are under ""
+func (togos TogoList) GroupByAssignee() map[string]TogoList {
+	groups := make(map[string]TogoList)
+	for i := range togos {
+		groups[togos[i].Assignee] = groups[togos[i].Assignee].Add(&togos[i])
+	}
+	return groups
+}
+
+// SplitByDay is GroupByDay in order: the togos sorted by time, one list for each day that has any
+func (togos TogoList) SplitByDay() []TogoList {
+	sorted := append(TogoList{}, togos...).SortByTime()
+	days := make([]TogoList, 0)
+	for start := 0; start < len(sorted); {
+		first := sorted[start].Date.StartOfDay()
+		end := start + 1
+		for end < len(sorted) && sorted[end].Date.StartOfDay().Equal(first.Time) {
+			end++
+		}
+		days = append(days, sorted[start:end])
+		start = end
+	}
+	return days
+}
+
+func (togos TogoList) GroupByDay() map[string]TogoList {
+	groups := make(map[string]TogoList)
+	for i := range togos {
+		local := togos[i].Date.ToLocal()
+		groups[local.Short()] = groups[local.Short()].Add(&togos[i])
+	}
+	return groups
+}
@@ -0,0 +1,287 @@
+package ToGo4BotPlus
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const CREATE_TEMPLATES_TABLES_QUERY string = `CREATE TABLE IF NOT EXISTS templates (id INTEGER PRIMARY KEY AUTOINCREMENT, owner_id BIGINT NOT NULL,
+	name VARCHAR(32) NOT NULL, UNIQUE (owner_id, name));
+	CREATE TABLE IF NOT EXISTS template_items (template_id INTEGER NOT NULL, position INTEGER NOT NULL, title VARCHAR(64) NOT NULL,
+	description VARCHAR(1024), weight INTEGER, extra INTEGER, time CHAR(5) NOT NULL, duration INTEGER, PRIMARY KEY (template_id, position))`
+
+const (
+	TemplateItemSeparator     = "+" // items of a template come one after another, each starting with +
+	TemplateTimeFormat        = "15:04"
+	MaximumTemplateNameLength = 32
+)
+
+// ---------------------- Template Structs & Receivers --------------------------------
+// TemplateItem is the spec of a togo in a template; its day is chosen when the template is used
+type TemplateItem struct {
+	Title       string
+	Description string
+	Weight      uint16
+	Extra       bool
+	Time        string // hh:mm
+	Duration    time.Duration
+}
+
+type Template struct {
+	Id      uint64
+	OwnerId int64
+	Name    string
+	Items   []TemplateItem
+}
+
+// ParseTemplateItems reads the items like: +  title  [=  weight]  [:  description]  [+x | -x]  @  hh:mm  [->  minutes]  +  next_title ...
+// items without a weight get defaultWeight, as + does
+func ParseTemplateItems(terms []string, defaultWeight uint16) (items []TemplateItem, err error) {
+	for i := 0; i < len(terms); i++ {
+		if terms[i] != TemplateItemSeparator || i+1 >= len(terms) {
+			return nil, errors.New("each item must start with +  title")
+		}
+		i++
+		item := TemplateItem{Title: terms[i], Weight: defaultWeight}
+		for ; i+1 < len(terms) && terms[i+1] != TemplateItemSeparator; i++ {
+			flag := terms[i+1]
+			if flag == "+x" || flag == "-x" {
+				item.Extra = flag == "+x"
+				continue
+			}
+			if i+2 >= len(terms) {
+				return nil, errors.New("the value of " + flag + " is missing in " + item.Title)
+			}
+			value := terms[i+2]
+			i++
+			switch flag {
+			case "=", "+w":
+				if _, err := fmt.Sscan(value, &item.Weight); err != nil {
+					return nil, err
+				}
+			case ":", "+d":
+				item.Description = value
+			case "@":
+				if start, err := time.Parse(TemplateTimeFormat, value); err != nil {
+					return nil, errors.New("time of " + item.Title + " must be like hh:mm")
+				} else {
+					item.Time = start.Format(TemplateTimeFormat)
+				}
+			case "->":
+				var minutes int
+				if _, err := fmt.Sscan(value, &minutes); err != nil || minutes <= 0 {
+					return nil, errors.New("duration must be positive integer")
+				}
+				item.Duration = time.Duration(minutes) * time.Minute
+			default:
+				return nil, errors.New("unknown flag in template item " + item.Title + ": " + flag)
+			}
+		}
+		if item.Time == "" {
+			return nil, errors.New("time of " + item.Title + " is missing; use @  hh:mm")
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("a template must have at least one item")
+	}
+	return
+}
+
+// Terms returns the item as the terms of + command, on the day that is daysFromNow days from today
+func (item *TemplateItem) Terms(daysFromNow int) []string {
+	terms := []string{item.Title, "=", fmt.Sprint(item.Weight), "@", fmt.Sprint(daysFromNow), item.Time}
+	if item.Description != "" {
+		terms = append(terms, ":", item.Description)
+	}
+	if item.Extra {
+		terms = append(terms, "+x")
+	}
+	if item.Duration > 0 {
+		terms = append(terms, "->", fmt.Sprint(int(item.Duration.Minutes())))
+	}
+	return terms
+}
+
+func (template *Template) ToString() string {
+	return template.Localize(DefaultLocale)
+}
+
+func (template *Template) Localize(locale Locale) string {
+	lines := []string{locale.Text("template", template.Name, len(template.Items))}
+	for i := range template.Items {
+		item := &template.Items[i]
+		line := locale.Text("template_item", item.Time, item.Title, item.Weight)
+		if item.Extra {
+			line += locale.Text("template_extra")
+		}
+		if item.Duration > 0 {
+			line += locale.Text("template_minutes", item.Duration.Minutes())
+		}
+		lines = append(lines, line)
+	}
+	return locale.Align(strings.Join(lines, "\n"))
+}
+
+// Instantiate creates the togos of the template, on the day that is daysFromNow days from today
+func (template *Template) Instantiate(daysFromNow int) (togos TogoList, err error) {
+	togos = make(TogoList, 0, len(template.Items))
+	for i := range template.Items {
+		item := &template.Items[i]
+		togo := Extract(template.OwnerId, item.Terms(daysFromNow), item.Weight)
+		if togo.Id, err = togo.Save(); err != nil {
+			return
+		}
+		togos = togos.Add(&togo)
+	}
+	return
+}
+
+// ---------------------- Template Functions --------------------------------
+const SELECT_TEMPLATE_ID_QUERY string = "SELECT id FROM templates WHERE owner_id=? AND name=?"
+const DELETE_TEMPLATE_ITEMS_QUERY string = "DELETE FROM template_items WHERE template_id=?"
+
+// SaveTemplate stores a new template, or replaces the items of the existing one with the same name if replace is true
+func SaveTemplate(template *Template, replace bool) error {
+	if template.Name == "" || len(template.Name) > MaximumTemplateNameLength {
+		return fmt.Errorf("template name must have 1 to %d characters", MaximumTemplateNameLength)
+	}
+	db, err := getDatabase()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	find, err := txStatement(tx, SELECT_TEMPLATE_ID_QUERY)
+	if err != nil {
+		return err
+	}
+	clear, err := txStatement(tx, DELETE_TEMPLATE_ITEMS_QUERY)
+	if err != nil {
+		return err
+	}
+	insertItem, err := txStatement(tx, `INSERT INTO template_items (template_id, position, title, description, weight, extra, time, duration)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	var id int64
+	err = find.QueryRow(template.OwnerId, template.Name).Scan(&id)
+	if err == sql.ErrNoRows {
+		if replace {
+			return errors.New("there is no template named " + template.Name)
+		}
+		insert, err := txStatement(tx, "INSERT INTO templates (owner_id, name) VALUES (?, ?)")
+		if err != nil {
+			return err
+		}
+		result, err := insert.Exec(template.OwnerId, template.Name)
+		if err != nil {
+			return err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if !replace {
+		return errors.New("a template named " + template.Name + " exists already")
+	}
+	if _, err := clear.Exec(id); err != nil {
+		return err
+	}
+	for position, item := range template.Items {
+		if _, err := insertItem.Exec(id, position, item.Title, item.Description, item.Weight, item.Extra, item.Time, item.Duration.Minutes()); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	template.Id = uint64(id)
+	return nil
+}
+
+// LoadTemplates loads the templates of the owner, sorted by name; if name is not empty, just that one is loaded
+func LoadTemplates(ownerId int64, name string) ([]Template, error) {
+	load, err := statement(`SELECT templates.id, templates.name, title, description, weight, extra, time, duration FROM templates
+		JOIN template_items ON template_items.template_id=templates.id WHERE owner_id=? AND (?='' OR name=?) ORDER BY name, time, position`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := load.Query(ownerId, name, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	templates := make([]Template, 0)
+	for rows.Next() {
+		var id uint64
+		var templateName string
+		var item TemplateItem
+		if err := rows.Scan(&id, &templateName, &item.Title, &item.Description, &item.Weight, &item.Extra, &item.Time, &item.Duration); err != nil {
+			return nil, err
+		}
+		item.Duration *= time.Minute
+		if count := len(templates); count == 0 || templates[count-1].Id != id {
+			templates = append(templates, Template{Id: id, OwnerId: ownerId, Name: templateName})
+		}
+		last := &templates[len(templates)-1]
+		last.Items = append(last.Items, item)
+	}
+	return templates, rows.Err()
+}
+
+func LoadTemplate(ownerId int64, name string) (*Template, error) {
+	templates, err := LoadTemplates(ownerId, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, errors.New("there is no template named " + name)
+	}
+	return &templates[0], nil
+}
+
+func RemoveTemplate(ownerId int64, name string) error {
+	db, err := getDatabase()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	find, err := txStatement(tx, SELECT_TEMPLATE_ID_QUERY)
+	if err != nil {
+		return err
+	}
+	clear, err := txStatement(tx, DELETE_TEMPLATE_ITEMS_QUERY)
+	if err != nil {
+		return err
+	}
+	remove, err := txStatement(tx, "DELETE FROM templates WHERE id=?")
+	if err != nil {
+		return err
+	}
+	var id int64
+	if err := find.QueryRow(ownerId, name).Scan(&id); err == sql.ErrNoRows {
+		return errors.New("there is no template named " + name)
+	} else if err != nil {
+		return err
+	}
+	if _, err := clear.Exec(id); err != nil {
+		return err
+	}
+	if _, err := remove.Exec(id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
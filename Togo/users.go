@@ -0,0 +1,183 @@
+package ToGo4BotPlus
+
+import (
+	"time"
+)
+
+const CREATE_USERS_TABLES_QUERY string = `CREATE TABLE IF NOT EXISTS users (id BIGINT PRIMARY KEY, username VARCHAR(64), first_name VARCHAR(64),
+	first_seen DATETIME, last_seen DATETIME, banned INTEGER DEFAULT 0);
+	CREATE TABLE IF NOT EXISTS activities (user_id BIGINT NOT NULL, day CHAR(10) NOT NULL, updates INTEGER DEFAULT 0,
+	togos_created INTEGER DEFAULT 0, PRIMARY KEY (user_id, day))`
+
+const ActivityDayFormat = "2006-01-02"
+
+// ---------------------- User Struct --------------------------------
+type User struct {
+	Id        int64 // telegram id
+	Username  string
+	FirstName string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Banned    bool
+}
+
+type DayActivity struct {
+	Day            string
+	ActiveUsers    uint64
+	TogosCreated   uint64
+	TogosCompleted uint64
+}
+
+type BotStatistics struct {
+	Users          uint64
+	BannedUsers    uint64
+	TogosCreated   uint64 // including the removed ones
+	TogosCurrent   uint64
+	TogosCompleted uint64
+	Days           []DayActivity // newest first
+}
+
+// ---------------------- User Functions --------------------------------
+// TouchUser registers the user if it's new, updates its info & today's activity and returns the stored user
+func TouchUser(id int64, username string, firstName string) (user User, err error) {
+	upsertUser, err := statement(`INSERT INTO users (id, username, first_name, first_seen, last_seen) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET username=excluded.username, first_name=excluded.first_name,
+		first_seen=COALESCE(first_seen, excluded.first_seen), last_seen=excluded.last_seen`)
+	if err != nil {
+		return
+	}
+	upsertActivity, err := statement(`INSERT INTO activities (user_id, day, updates) VALUES (?, ?, 1)
+		ON CONFLICT(user_id, day) DO UPDATE SET updates=updates+1`)
+	if err != nil {
+		return
+	}
+	selectUser, err := statement("SELECT id, username, first_name, first_seen, last_seen, banned FROM users WHERE id=?")
+	if err != nil {
+		return
+	}
+	now := Today()
+	if _, err = upsertUser.Exec(id, username, firstName, now.Time, now.Time); err != nil {
+		return
+	}
+	if _, err = upsertActivity.Exec(id, now.Format(ActivityDayFormat)); err != nil {
+		return
+	}
+	var banned int
+	err = selectUser.QueryRow(id).Scan(&user.Id, &user.Username, &user.FirstName, &user.FirstSeen, &user.LastSeen, &banned)
+	user.Banned = banned != 0
+	return
+}
+
+func countCreatedTogos(ownerId int64, count int) error {
+	countCreated, err := statement(`INSERT INTO activities (user_id, day, togos_created) VALUES (?, ?, ?)
+		ON CONFLICT(user_id, day) DO UPDATE SET togos_created=togos_created+excluded.togos_created`)
+	if err != nil {
+		return err
+	}
+	_, err = countCreated.Exec(ownerId, Today().Format(ActivityDayFormat), count)
+	return err
+}
+
+func SetBanned(id int64, banned bool) error {
+	// the user may have not used the bot yet
+	ban, err := statement(`INSERT INTO users (id, first_seen, last_seen, banned) VALUES (?, NULL, NULL, ?)
+		ON CONFLICT(id) DO UPDATE SET banned=excluded.banned`)
+	if err != nil {
+		return err
+	}
+	value := 0
+	if banned {
+		value = 1
+	}
+	_, err = ban.Exec(id, value)
+	return err
+}
+
+// LoadUsers returns all the users that are not banned
+func LoadUsers() ([]int64, error) {
+	load, err := statement("SELECT id FROM users WHERE banned=0 AND last_seen IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := load.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+func LoadBotStatistics(days int) (stats BotStatistics, err error) {
+	count := func(query string, counts ...interface{}) error {
+		load, err := statement(query)
+		if err != nil {
+			return err
+		}
+		return load.QueryRow().Scan(counts...)
+	}
+	if err = count("SELECT COUNT(*), COALESCE(SUM(banned), 0) FROM users WHERE last_seen IS NOT NULL", &stats.Users, &stats.BannedUsers); err != nil {
+		return
+	}
+	if err = count("SELECT COUNT(*), COALESCE(SUM(progress >= 100), 0) FROM togos", &stats.TogosCurrent, &stats.TogosCompleted); err != nil {
+		return
+	}
+	// AUTOINCREMENT keeps the largest id ever used, even if the togo is removed
+	if err = count("SELECT COALESCE(MAX(seq), 0) FROM sqlite_sequence WHERE name='togos'", &stats.TogosCreated); err != nil {
+		return
+	}
+
+	today := Today().StartOfDay()
+	stats.Days = make([]DayActivity, days)
+	index := make(map[string]*DayActivity)
+	for i := range stats.Days {
+		stats.Days[i].Day = today.AddDays(-i).Format(ActivityDayFormat)
+		index[stats.Days[i].Day] = &stats.Days[i]
+	}
+	since := today.AddDays(1 - days)
+	activities, err := statement("SELECT day, SUM(updates > 0), SUM(togos_created) FROM activities WHERE day >= ? GROUP BY day")
+	if err != nil {
+		return
+	}
+	rows, err := activities.Query(since.Format(ActivityDayFormat))
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var day string
+		var active, created uint64
+		if rows.Scan(&day, &active, &created) == nil {
+			if activity, ok := index[day]; ok {
+				activity.ActiveUsers, activity.TogosCreated = active, created
+			}
+		}
+	}
+	rows.Close()
+
+	// completions are counted on the day of the togo; dates are compared in UTC by datetime(), as Load does
+	completions, err := statement("SELECT date FROM togos WHERE progress >= 100 AND datetime(date) >= datetime(?)")
+	if err != nil {
+		return
+	}
+	rows, err = completions.Query(since.Time)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date time.Time
+		if rows.Scan(&date) == nil {
+			if activity, ok := index[Date{date}.ToLocal().Format(ActivityDayFormat)]; ok {
+				activity.TogosCompleted++
+			}
+		}
+	}
+	err = rows.Err()
+	return
+}
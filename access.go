@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+// ---------------------- Access Policy --------------------------------
+type AccessMode string
+
+const (
+	OpenAccess      AccessMode = "open"
+	AllowlistAccess AccessMode = "allowlist"
+	InviteAccess    AccessMode = "invite"
+)
+
+type AccessPolicy struct {
+	Mode      AccessMode
+	Allowlist map[int64]bool
+}
+
+var accessPolicy AccessPolicy
+
+// LoadAccessPolicy reads ACCESS_MODE (open, allowlist or invite) and the comma separated ACCESS_ALLOWLIST from the .env file
+func LoadAccessPolicy() (policy AccessPolicy) {
+	policy = AccessPolicy{Mode: AccessMode(strings.ToLower(env["ACCESS_MODE"])), Allowlist: make(map[int64]bool)}
+	if policy.Mode != AllowlistAccess && policy.Mode != InviteAccess {
+		policy.Mode = OpenAccess
+	}
+	for _, value := range strings.Split(env["ACCESS_ALLOWLIST"], ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			policy.Allowlist[id] = true
+		}
+	}
+	return
+}
+
+func (policy *AccessPolicy) Allows(userId int64, chatId int64) bool {
+	if policy.Mode == OpenAccess || IsAdmin(userId) || IsAdmin(chatId) || policy.Allowlist[userId] || policy.Allowlist[chatId] {
+		return true
+	}
+	granted, err := Togo.IsGranted(userId, chatId)
+	if err != nil {
+		log.Println(err)
+	}
+	return granted
+}
+
+// Authorize stands in front of HandleUpdate; updates from users that are not allowed or banned never reach the handler.
+// It also handles redeeming invite codes, sent as /start CODE by telegram deep links.
+func (policy *AccessPolicy) Authorize(telegramBot *TelegramBotAPI, update *tgbotapi.Update) bool {
+	var user *tgbotapi.User
+	var chatId int64
+	text := ""
+	if update.Message != nil {
+		user, chatId, text = update.Message.From, update.Message.Chat.ID, update.Message.Text
+	} else if update.CallbackQuery != nil && update.CallbackQuery.Message != nil {
+		user, chatId = update.CallbackQuery.From, update.CallbackQuery.Message.Chat.ID
+	}
+	if user == nil {
+		return false
+	}
+	userId := int64(user.ID)
+	response := TelegramResponse{TargetChatId: chatId}
+
+	fields := strings.Fields(text)
+	if len(fields) > 0 {
+		fields[0], _ = telegramBot.CommandOf(fields[0])
+	}
+	if policy.Mode == InviteAccess && len(fields) == 2 && fields[0] == "/start" {
+		if err := Togo.RedeemInvite(fields[1], chatId); err != nil {
+			response.TextMsg = err.Error()
+		} else {
+			response.TextMsg = LocaleOf(chatId, user).Text("welcome")
+			response.ReplyMarkup = MainKeyboardMenu()
+		}
+		telegramBot.SendTextMessage(response)
+		return false
+	}
+	if !policy.Allows(userId, chatId) {
+		response.TextMsg = LocaleOf(chatId, user).Text("access_denied")
+		if update.Message != nil {
+			telegramBot.SendTextMessage(response)
+		}
+		return false
+	}
+	if stored, err := Togo.TouchUser(userId, user.UserName, user.FirstName); err != nil {
+		log.Println(err)
+	} else if stored.Banned {
+		response.TextMsg = LocaleOf(chatId, user).Text("banned")
+		if update.Message != nil {
+			telegramBot.SendTextMessage(response)
+		}
+		return false
+	}
+	return true
+}
+
+// ---------------------- Access Admin Commands --------------------------------
+func (telegramBot *TelegramBotAPI) NewInvite(adminId int64, locale Togo.Locale) string {
+	code, err := Togo.CreateInvite(adminId)
+	if err != nil {
+		return err.Error()
+	}
+	return locale.Text("invite", code, telegramBot.Self.UserName, code)
+}
+
+func SetAccess(adminId int64, terms []string, allowed bool) string {
+	if len(terms) == 0 {
+		return "You must provide the telegram id!"
+	}
+	id, err := strconv.ParseInt(terms[0], 10, 64)
+	if err != nil {
+		return err.Error()
+	}
+	if allowed {
+		err = Togo.Grant(id, adminId)
+	} else {
+		err = Togo.Revoke(id)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	if allowed {
+		return fmt.Sprint(id, " is allowed.")
+	}
+	return fmt.Sprint(id, " is not allowed anymore.")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+const (
+	NumberOfDaysInBotStatistics = 7
+	BroadcastInterval           = time.Second / 20 // telegram allows about 30 messages per second
+)
+
+// ---------------------- Admin Functions --------------------------------
+// AdminIds reads the comma separated ADMIN_IDS from the .env file; ADMIN_ID is still supported for a single admin
+func AdminIds() (ids []int64) {
+	for _, value := range strings.Split(env["ADMIN_IDS"]+","+env["ADMIN_ID"], ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return
+}
+
+func IsAdmin(chatId int64) bool {
+	for _, id := range AdminIds() {
+		if id == chatId {
+			return true
+		}
+	}
+	return false
+}
+
+func (telegramBot *TelegramBotAPI) InformAdmin(news string) {
+	admins := AdminIds()
+	if len(admins) == 0 {
+		log.Println("Cannot get admin id to inform him/her; news is: ", news)
+	}
+	for _, admin := range admins {
+		response := TelegramResponse{TextMsg: news, TargetChatId: admin} // default method is sendMessage
+		telegramBot.SendTextMessage(response)
+	}
+}
+
+func BotStatisticsToString() string {
+	stats, err := Togo.LoadBotStatistics(NumberOfDaysInBotStatistics)
+	if err != nil {
+		return err.Error()
+	}
+	text := fmt.Sprintf("Users: %d (%d banned)\nTogos: %d created, %d existing, %d completed\n\nDay: active users | togos created | togos completed\n",
+		stats.Users, stats.BannedUsers, stats.TogosCreated, stats.TogosCurrent, stats.TogosCompleted)
+	for _, day := range stats.Days {
+		text += fmt.Sprintf("%s: %d | %d | %d\n", day.Day, day.ActiveUsers, day.TogosCreated, day.TogosCompleted)
+	}
+	if dispatcher != nil {
+		text += fmt.Sprintf("\nUpdates in queue: %d\n", dispatcher.Depth())
+	}
+	if scheduler != nil {
+		text += fmt.Sprintf("Reminders scheduled: %d\n", scheduler.Len())
+	}
+	return text
+}
+
+// Broadcast sends the message to all users that are not banned, one by one, so that telegram doesn't limit the bot
+func (telegramBot *TelegramBotAPI) Broadcast(ctx context.Context, adminId int64, message string) {
+	users, err := Togo.LoadUsers()
+	if err != nil {
+		telegramBot.SendTextMessage(TelegramResponse{TextMsg: err.Error(), TargetChatId: adminId})
+		return
+	}
+	sent, failed := 0, 0
+	for _, user := range users {
+		if ctx.Err() != nil {
+			// the bot is shutting down
+			break
+		}
+		if _, err := telegramBot.Send(tgbotapi.NewMessage(user, message)); err != nil {
+			failed++
+		} else {
+			sent++
+		}
+		time.Sleep(BroadcastInterval)
+	}
+	telegramBot.SendTextMessage(TelegramResponse{TextMsg: fmt.Sprintf("Broadcast finished: %d sent, %d failed, %d not sent.",
+		sent, failed, len(users)-sent-failed), TargetChatId: adminId})
+}
+
+func SetBanned(terms []string, banned bool) string {
+	if len(terms) == 0 {
+		return "You must provide the telegram id!"
+	}
+	id, err := strconv.ParseInt(terms[0], 10, 64)
+	if err != nil {
+		return err.Error()
+	}
+	if IsAdmin(id) {
+		return "Admins can not be banned!"
+	}
+	if err := Togo.SetBanned(id, banned); err != nil {
+		return err.Error()
+	}
+	if banned {
+		return fmt.Sprint(id, " is banned.")
+	}
+	return fmt.Sprint(id, " is unbanned.")
+}
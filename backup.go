@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+const (
+	DefaultBackupDirectory = "./backups"
+	DefaultBackupInterval  = 24 * 60 // minutes
+	DefaultBackupsToKeep   = 7
+	BackupFilePrefix       = "togos-"
+	BackupFileSuffix       = ".db"
+	BackupTimeFormat       = "20060102-150405"
+)
+
+// ---------------------- Backup Policy --------------------------------
+type BackupPolicy struct {
+	Directory   string
+	Interval    time.Duration // zero means no scheduled backups
+	Keep        int
+	SendToAdmin bool
+}
+
+// LoadBackupPolicy reads BACKUP_DIR, BACKUP_INTERVAL (minutes), BACKUP_KEEP and BACKUP_SEND_TO_ADMIN from the .env file
+func LoadBackupPolicy() (policy BackupPolicy) {
+	policy = BackupPolicy{Directory: DefaultBackupDirectory, Interval: DefaultBackupInterval * time.Minute, Keep: DefaultBackupsToKeep}
+	if directory := env["BACKUP_DIR"]; directory != "" {
+		policy.Directory = directory
+	}
+	if minutes, err := strconv.Atoi(env["BACKUP_INTERVAL"]); err == nil && minutes >= 0 {
+		policy.Interval = time.Duration(minutes) * time.Minute
+	}
+	if keep, err := strconv.Atoi(env["BACKUP_KEEP"]); err == nil && keep > 0 {
+		policy.Keep = keep
+	}
+	policy.SendToAdmin, _ = strconv.ParseBool(env["BACKUP_SEND_TO_ADMIN"])
+	return
+}
+
+// ListBackups returns the snapshot file names in the backup directory, newest first
+func (policy *BackupPolicy) ListBackups() ([]string, error) {
+	entries, err := os.ReadDir(policy.Directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	backups := make([]string, 0)
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasPrefix(name, BackupFilePrefix) && strings.HasSuffix(name, BackupFileSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	// the names contain the time, so sorting the names sorts the backups by time
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// TakeBackup takes a snapshot of the database and removes the old ones, exceeding the retention policy
+func (policy *BackupPolicy) TakeBackup() (string, error) {
+	path, err := policy.Snapshot()
+	if err != nil {
+		return "", err
+	}
+	return path, policy.Prune()
+}
+
+// Snapshot takes a snapshot of the database, without removing any old ones
+func (policy *BackupPolicy) Snapshot() (string, error) {
+	if err := os.MkdirAll(policy.Directory, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(policy.Directory, BackupFilePrefix+time.Now().Format(BackupTimeFormat)+BackupFileSuffix)
+	if err := Togo.Backup(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Prune removes the oldest snapshots, exceeding the retention policy
+func (policy *BackupPolicy) Prune() error {
+	backups, err := policy.ListBackups()
+	if err != nil {
+		return err
+	}
+	for i := policy.Keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(policy.Directory, backups[i])); err != nil {
+			log.Println("cannot remove old backup: ", err)
+		}
+	}
+	return nil
+}
+
+// BackupPath returns the path of a backup, making sure the name doesn't point outside the backup directory
+func (policy *BackupPolicy) BackupPath(name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, BackupFilePrefix) || !strings.HasSuffix(name, BackupFileSuffix) {
+		return "", errors.New("invalid backup name")
+	}
+	return filepath.Join(policy.Directory, name), nil
+}
+
+// ---------------------- Backup Scheduler --------------------------------
+func (telegramBot *TelegramBotAPI) SendBackup(chatId int64, path string) error {
+	msg := tgbotapi.NewDocumentUpload(chatId, path)
+	msg.Caption = filepath.Base(path)
+	_, err := telegramBot.Send(msg)
+	return err
+}
+
+func (telegramBot *TelegramBotAPI) BackupPeriodically(ctx context.Context, policy BackupPolicy) {
+	if policy.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		path, err := policy.TakeBackup()
+		if err != nil {
+			telegramBot.InformAdmin(fmt.Sprintln("scheduled backup failed: ", err.Error()))
+			if path == "" {
+				continue
+			}
+		}
+		if policy.SendToAdmin {
+			for _, admin := range AdminIds() {
+				if err = telegramBot.SendBackup(admin, path); err != nil {
+					log.Println("cannot send the backup to admin: ", err)
+				}
+			}
+		}
+	}
+}
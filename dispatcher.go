@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	DefaultNumberOfWorkers = 8
+	DefaultWorkerQueueSize = 64
+)
+
+// ---------------------- Update Offset Tracker --------------------------------
+// offsetTracker finds the last update id that it and all the updates before it are handled,
+// since updates of different chats may finish in any order.
+type offsetTracker struct {
+	mu       sync.Mutex
+	pending  map[int]bool
+	last     int // the largest id handed over
+	complete int // the largest id that all updates up to it are handled
+}
+
+func (tracker *offsetTracker) Start(id int) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.pending[id] = true
+	if id > tracker.last {
+		tracker.last = id
+	}
+}
+
+// Cancel forgets an update that is never handed to a worker; it must be the last one started
+func (tracker *offsetTracker) Cancel(id int) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	delete(tracker.pending, id)
+	if id == tracker.last {
+		tracker.last = id - 1
+	}
+}
+
+// Done marks the update as handled and returns the new complete id, if it's changed
+func (tracker *offsetTracker) Done(id int) (int, bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	delete(tracker.pending, id)
+	complete := tracker.last
+	if len(tracker.pending) > 0 {
+		ids := make([]int, 0, len(tracker.pending))
+		for pending := range tracker.pending {
+			ids = append(ids, pending)
+		}
+		sort.Ints(ids)
+		complete = ids[0] - 1
+	}
+	if complete > tracker.complete {
+		tracker.complete = complete
+		return complete, true
+	}
+	return tracker.complete, false
+}
+
+// ---------------------- Dispatcher --------------------------------
+// Dispatcher handles the updates of different chats in parallel, while the updates of each chat
+// are always handled by the same worker, one by one, in the order they are received.
+type Dispatcher struct {
+	queues  []chan tgbotapi.Update
+	depth   int64
+	handle  func(update tgbotapi.Update)
+	workers sync.WaitGroup
+	offsets offsetTracker
+	saveMu  sync.Mutex
+	saved   int // the last update id saved in the database
+}
+
+func NewDispatcher(numberOfWorkers int, queueSize int, handle func(update tgbotapi.Update)) *Dispatcher {
+	dispatcher := &Dispatcher{queues: make([]chan tgbotapi.Update, numberOfWorkers), handle: handle,
+		offsets: offsetTracker{pending: make(map[int]bool)}}
+	for i := range dispatcher.queues {
+		dispatcher.queues[i] = make(chan tgbotapi.Update, queueSize)
+		dispatcher.workers.Add(1)
+		go dispatcher.work(dispatcher.queues[i])
+	}
+	return dispatcher
+}
+
+// LoadDispatcher reads WORKERS and WORKER_QUEUE_SIZE from the .env file
+func LoadDispatcher(handle func(update tgbotapi.Update)) *Dispatcher {
+	numberOfWorkers, queueSize := DefaultNumberOfWorkers, DefaultWorkerQueueSize
+	if value, err := strconv.Atoi(env["WORKERS"]); err == nil && value > 0 {
+		numberOfWorkers = value
+	}
+	if value, err := strconv.Atoi(env["WORKER_QUEUE_SIZE"]); err == nil && value > 0 {
+		queueSize = value
+	}
+	return NewDispatcher(numberOfWorkers, queueSize, handle)
+}
+
+func (dispatcher *Dispatcher) work(queue chan tgbotapi.Update) {
+	defer dispatcher.workers.Done()
+	for update := range queue {
+		dispatcher.handle(update)
+		atomic.AddInt64(&dispatcher.depth, -1)
+		if complete, changed := dispatcher.offsets.Done(update.UpdateID); changed {
+			dispatcher.saveOffset(complete)
+		}
+	}
+}
+
+// saveOffset makes sure the saved offset never goes back, when workers finish at the same time
+func (dispatcher *Dispatcher) saveOffset(complete int) {
+	dispatcher.saveMu.Lock()
+	defer dispatcher.saveMu.Unlock()
+	if complete > dispatcher.saved {
+		SaveUpdateOffset(complete)
+		dispatcher.saved = complete
+	}
+}
+
+func ChatIdOf(update *tgbotapi.Update) int64 {
+	if update.Message != nil {
+		return update.Message.Chat.ID
+	} else if update.CallbackQuery != nil && update.CallbackQuery.Message != nil {
+		return update.CallbackQuery.Message.Chat.ID
+	}
+	return int64(update.UpdateID)
+}
+
+// Dispatch queues the update for its chat's worker; when the queue is full, it waits (so polling waits too),
+// unless ctx is canceled, which means the update is not queued and false is returned.
+func (dispatcher *Dispatcher) Dispatch(ctx context.Context, update tgbotapi.Update) bool {
+	chatId := ChatIdOf(&update)
+	if chatId < 0 {
+		chatId = -chatId // groups have negative ids
+	}
+	queue := dispatcher.queues[chatId%int64(len(dispatcher.queues))]
+	dispatcher.offsets.Start(update.UpdateID)
+	atomic.AddInt64(&dispatcher.depth, 1)
+	select {
+	case queue <- update:
+		return true
+	case <-ctx.Done():
+		atomic.AddInt64(&dispatcher.depth, -1)
+		dispatcher.offsets.Cancel(update.UpdateID)
+		return false
+	}
+}
+
+// Depth is the number of updates queued or being handled
+func (dispatcher *Dispatcher) Depth() int64 {
+	return atomic.LoadInt64(&dispatcher.depth)
+}
+
+// Close stops accepting updates and waits for the workers to handle the ones already queued
+func (dispatcher *Dispatcher) Close() {
+	for _, queue := range dispatcher.queues {
+		close(queue)
+	}
+	dispatcher.workers.Wait()
+}
+
+// ---------------------- Monitoring --------------------------------
+// ServeMetrics publishes the queue depth in expvar, served on METRICS_ADDR (like localhost:8080/debug/vars), if it's set
+func ServeMetrics(ctx context.Context) {
+	expvar.Publish("update_queue_depth", expvar.Func(func() interface{} { return dispatcher.Depth() }))
+	address := env["METRICS_ADDR"]
+	if address == "" {
+		return
+	}
+	server := &http.Server{Addr: address}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Println("metrics server stopped: ", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+// telegram's bot commands: a slash, the command and the optional username of the bot
+var addressedCommand = regexp.MustCompile(`^(/[A-Za-z0-9_]+)@([A-Za-z0-9_]+)$`)
+
+// ---------------------- Group Chats --------------------------------
+// CommandOf strips the bot's username from commands like /cmd@botname, used in groups;
+// ok is false if the command is addressed to another bot. Other terms, like paths, are returned as they are.
+func (telegramBot *TelegramBotAPI) CommandOf(term string) (command string, ok bool) {
+	parts := addressedCommand.FindStringSubmatch(term)
+	if parts == nil {
+		return term, true
+	}
+	return parts[1], strings.EqualFold(parts[2], telegramBot.Self.UserName)
+}
+
+func IsGroup(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// MembersProgressToString shows the progress of each member on the togos assigned to them, sorted by username
+func MembersProgressToString(togos Togo.TogoList, locale Togo.Locale) string {
+	groups := togos.GroupByAssignee()
+	members := make([]string, 0, len(groups))
+	for member := range groups {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	text := "\n" + locale.Text("members") + "\n"
+	for _, member := range members {
+		progress, _, completed, extra, total := groups[member].Subtotal()
+		name := locale.Text("unassigned")
+		if member != "" {
+			name = "@" + member
+		}
+		text += fmt.Sprintf("%s: %3.2f%% (%d / %d", name, progress, completed, total)
+		if extra > 0 {
+			text += fmt.Sprintf(" [+%d]", extra)
+		}
+		text += ")\n"
+	}
+	return text
+}
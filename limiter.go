@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCommandsPerMinute = 30
+	DefaultCommandsBurst     = 10
+	DefaultMessagesPerMinute = 60
+	DefaultMessagesBurst     = 20
+	MaximumIdleBuckets       = 10000
+)
+
+// ---------------------- Token Bucket Rate Limiter --------------------------------
+type TokenBucket struct {
+	tokens float64
+	last   time.Time
+	warned bool // the user is warned since the last time a token was taken
+}
+
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[int64]*TokenBucket
+}
+
+func NewRateLimiter(perMinute int, burst int) *RateLimiter {
+	return &RateLimiter{rate: float64(perMinute) / 60, burst: float64(burst), buckets: make(map[int64]*TokenBucket)}
+}
+
+// LoadRateLimiter reads <PREFIX>_PER_MINUTE and <PREFIX>_BURST from the .env file
+func LoadRateLimiter(prefix string, perMinute int, burst int) *RateLimiter {
+	if value, err := strconv.Atoi(env[prefix+"_PER_MINUTE"]); err == nil && value > 0 {
+		perMinute = value
+	}
+	if value, err := strconv.Atoi(env[prefix+"_BURST"]); err == nil && value > 0 {
+		burst = value
+	}
+	return NewRateLimiter(perMinute, burst)
+}
+
+// Take tries to take a token from the bucket of id; warn is true only for the first refusal,
+// so that the user is warned just once until it's allowed again. A nil limiter allows everything.
+func (limiter *RateLimiter) Take(id int64) (allowed bool, warn bool) {
+	if limiter == nil {
+		return true, false
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	now := time.Now()
+	bucket, ok := limiter.buckets[id]
+	if !ok {
+		if len(limiter.buckets) >= MaximumIdleBuckets {
+			limiter.prune(now)
+		}
+		bucket = &TokenBucket{tokens: limiter.burst, last: now}
+		limiter.buckets[id] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * limiter.rate
+	if bucket.tokens > limiter.burst {
+		bucket.tokens = limiter.burst
+	}
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.warned = false
+		return true, false
+	}
+	warn = !bucket.warned
+	bucket.warned = true
+	return false, warn
+}
+
+// prune removes the buckets that are full again, since they are the same as new ones
+func (limiter *RateLimiter) prune(now time.Time) {
+	for id, bucket := range limiter.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*limiter.rate >= limiter.burst {
+			delete(limiter.buckets, id)
+		}
+	}
+}
+
+// ---------------------- Command Detection --------------------------------
+func IsCommand(term string) bool {
+	switch term {
+	case "+", "#", "%", "$", "✅", "❌":
+		return true
+	}
+	return strings.HasPrefix(term, "/")
+}
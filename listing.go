@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+const (
+	MaximumMessageLength = 4096 // telegram's limit, in UTF-16 code units
+	MaximumSubtotalDays  = 31   // % shows the subtotal of each day, in the ranges up to this long
+)
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// ---------------------- Listings --------------------------------
+// messageLength is the length of the text as telegram counts it; tags are counted too, so it's never less than the real one
+func messageLength(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+// SplitMessage splits the HTML text into messages no longer than limit, between lines; a single line longer than
+// limit loses its tags and is split between its characters, but never in the middle of an escaped entity like &amp;
+func SplitMessage(text string, limit int) []string {
+	messages := make([]string, 0, 1)
+	current := ""
+	flush := func() {
+		if current != "" {
+			messages = append(messages, current)
+			current = ""
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if current != "" && messageLength(current)+1+messageLength(line) <= limit {
+			current += "\n" + line
+			continue
+		}
+		flush()
+		if messageLength(line) <= limit {
+			current = line
+			continue
+		}
+		runes := []rune(htmlTags.ReplaceAllString(line, ""))
+		for len(runes) > 0 {
+			end, length := 0, 0
+			for end < len(runes) && length+len(utf16.Encode(runes[end:end+1])) <= limit {
+				length += len(utf16.Encode(runes[end : end+1]))
+				end++
+			}
+			if entity := strings.LastIndex(string(runes[:end]), "&"); end < len(runes) && entity > 0 &&
+				!strings.Contains(string(runes[:end])[entity:], ";") {
+				end = len([]rune(string(runes[:end])[:entity]))
+			}
+			messages = append(messages, string(runes[:end]))
+			runes = runes[end:]
+		}
+	}
+	flush()
+	return messages
+}
+
+// SendHTML sends the HTML text, in as many messages as needed
+func (telegramBot *TelegramBotAPI) SendHTML(response TelegramResponse, text string) {
+	response.ParseMode = tgbotapi.ModeHTML
+	for _, message := range SplitMessage(text, MaximumMessageLength) {
+		response.TextMsg = message
+		telegramBot.SendTextMessage(response)
+	}
+}
+
+// SendListing sends the togos as one HTML message per group, as the options say; it returns the number of togos sent
+func (telegramBot *TelegramBotAPI) SendListing(response TelegramResponse, togos Togo.TogoList, options Togo.ListingOptions, locale Togo.Locale, separator string) int {
+	for _, group := range togos.ListingHTML(options, locale, separator, nil) {
+		telegramBot.SendHTML(response, group)
+	}
+	if options.JustUndones {
+		return len(Undones(togos))
+	}
+	return len(togos)
+}
+
+// Undones is the togos that are not completed, nor skipped, yet
+func Undones(togos Togo.TogoList) Togo.TogoList {
+	undones := make(Togo.TogoList, 0, len(togos))
+	for i := range togos {
+		if togos[i].Progress < 100 && !togos[i].Skipped {
+			undones = undones.Add(&togos[i])
+		}
+	}
+	return undones
+}
+
+// ReadListingOptions reads the options after the # or % at terms[i], in any order: a range, -, sort  key  and
+// group  key, like  #  week  -  sort  weight; it returns the index of the last term it has read.
+// The older forms  # -,  # +a  and  # -a  still work.
+func ReadListingOptions(terms []string, i int, calendar Togo.Calendar) (within Togo.Range, options Togo.ListingOptions, last int, err error) {
+	within, options, last = Togo.TodayRange(), Togo.DefaultListingOptions, i
+	ranged := false
+	for ; last+1 < len(terms); last++ {
+		switch term := terms[last+1]; {
+		case term == "-":
+			options.JustUndones = true
+		case term == "-a" && !ranged:
+			within, options.JustUndones, ranged = Togo.AllDays(), true, true
+		case term == "sort" || term == "group":
+			if last+2 >= len(terms) {
+				err = errors.New(term + " needs a key, like: " + term + "  weight")
+				return
+			}
+			if term == "sort" {
+				options.SortBy, err = Togo.ParseSortKey(terms[last+2])
+			} else {
+				options.GroupBy, err = Togo.ParseGroupKey(terms[last+2])
+			}
+			if err != nil {
+				return
+			}
+			last++
+		default:
+			if ranged {
+				return
+			}
+			r, e := Togo.ParseRange(term, calendar)
+			if e != nil {
+				return // not an option; maybe the next command
+			}
+			within, ranged = r, true
+		}
+	}
+	return
+}
+
+// TodayOrAll is the range of the menus that show today's togos, or all of them
+func TodayOrAll(allDays bool) Togo.Range {
+	if allDays {
+		return Togo.AllDays()
+	}
+	return Togo.TodayRange()
+}
+
+// GroupSubtotals shows the progress of each group, like  📅 2024-10-19: 75.00% (3 / 4)
+func GroupSubtotals(groups []Togo.TogoGroup, locale Togo.Locale) string {
+	text := ""
+	for _, group := range groups {
+		progress, _, completed, extra, total := group.Togos.Subtotal()
+		text += fmt.Sprintf("\n%s: %3.2f%% (%d / %d)", group.Title, progress, completed, total)
+		if extra > 0 {
+			text += fmt.Sprintf("[+%d]", extra)
+		}
+	}
+	return locale.Align(text)
+}
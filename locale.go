@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+// symbols typed by the persian keyboard, that mean the same commands
+var symbolAliases = map[string]string{"٪": "%", "٬": ",", "＃": "#"}
+
+// ---------------------- Localization --------------------------------
+// LocaleOf is the language, calendar and date format chosen by the chat; if no language is chosen yet, the language of
+// the user's telegram app is used, and if no calendar is chosen, the usual calendar of the language
+func LocaleOf(chatId int64, user *tgbotapi.User) Togo.Locale {
+	locale := Togo.DefaultLocale
+	if settings, err := Togo.LoadSettings(chatId); err == nil {
+		locale.DateFormat = settings.DateFormat
+	}
+	if language, chosen := Togo.LoadLanguage(chatId); chosen {
+		locale.Language = language
+	} else if user != nil {
+		if language, ok := Togo.ParseLanguage(user.LanguageCode); ok {
+			locale.Language = language
+		}
+	}
+	if calendar, chosen := Togo.LoadCalendar(chatId); chosen {
+		locale.Calendar = calendar
+	} else {
+		locale.Calendar = locale.DefaultCalendar()
+	}
+	return locale
+}
+
+// SetLanguage handles /language  code; it returns the new locale, or the current one if the code is unknown
+func SetLanguage(chatId int64, code string, current Togo.Locale) Togo.Locale {
+	language, ok := Togo.ParseLanguage(code)
+	if !ok {
+		return current
+	}
+	if err := Togo.SetLanguage(chatId, language); err != nil {
+		log.Println(err)
+		return current
+	}
+	if _, chosen := Togo.LoadCalendar(chatId); !chosen {
+		current.Calendar = language.DefaultCalendar()
+	}
+	current.Language = language
+	return current
+}
+
+// SetCalendar handles /calendar  name, like SetLanguage
+func SetCalendar(chatId int64, name string, current Togo.Locale) Togo.Locale {
+	calendar, ok := Togo.ParseCalendar(name)
+	if !ok {
+		return current
+	}
+	if err := Togo.SetCalendar(chatId, calendar); err != nil {
+		log.Println(err)
+		return current
+	}
+	current.Calendar = calendar
+	return current
+}
+
+// NormalizeTerm keeps the commands working with any keyboard: symbol aliases are replaced, and the digits of the
+// terms that are just numbers, times or dates (not titles or descriptions) become latin.
+func NormalizeTerm(term string) string {
+	if alias, ok := symbolAliases[term]; ok {
+		return alias
+	}
+	if strings.IndexFunc(term, func(r rune) bool { return !unicode.IsDigit(r) && !strings.ContainsRune(":-+./", r) }) < 0 {
+		return Togo.NormalizeDigits(term)
+	}
+	return term
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
@@ -17,6 +25,11 @@ const (
 	MaximumInlineButtonTextLength = 24
 	MaximumNumberOfRowItems       = 3
 	NumberOfSeparatorSpaces       = 2
+	DefaultStatisticsDays         = 7
+	MaximumStatisticsDays         = 90
+	DefaultStreakTarget           = 80.0
+	NumberOfDaysInCharts          = 30
+	MaximumImportFileSize         = 1 << 20
 )
 
 type TelegramResponse struct {
@@ -26,6 +39,7 @@ type TelegramResponse struct {
 	MessageBeingEditedId int                            `json:"message_id,omitempty"` // for edit message & etc
 	ReplyMarkup          *tgbotapi.ReplyKeyboardMarkup  `json:"reply_markup,omitempty"`
 	InlineKeyboard       *tgbotapi.InlineKeyboardMarkup `json:"inline_keyboard,omitempty"`
+	ParseMode            string                         `json:"parse_mode,omitempty"` // tgbotapi.ModeHTML for the texts rendered as HTML
 	// file/photo?
 }
 
@@ -36,18 +50,40 @@ type TelegramAPIMethods interface {
 
 type TelegramBotAPI struct {
 	*tgbotapi.BotAPI
+	CommandLimiter *RateLimiter    // incoming commands of each user
+	MessageLimiter *RateLimiter    // outgoing messages to each chat
+	Context        context.Context // canceled when the bot is shutting down
+	routines       sync.WaitGroup
+}
+
+// AllowMessage checks the outgoing rate limit of the chat; the first message over the limit is replaced by a warning
+func (telegramBotAPI *TelegramBotAPI) AllowMessage(chatId int64) bool {
+	allowed, warn := telegramBotAPI.MessageLimiter.Take(chatId)
+	if warn {
+		telegramBotAPI.Send(tgbotapi.NewMessage(chatId, LocaleOf(chatId, nil).Text("messages_limit")))
+	}
+	return allowed
 }
 
 func (telegramBotAPI *TelegramBotAPI) SendTextMessage(response TelegramResponse) {
+	telegramBotAPI.TrySendTextMessage(response)
+}
+
+// TrySendTextMessage is SendTextMessage for the messages that must not be lost: it tells if the message is dropped by the rate limit or not sent
+func (telegramBotAPI *TelegramBotAPI) TrySendTextMessage(response TelegramResponse) error {
+	if !telegramBotAPI.AllowMessage(response.TargetChatId) {
+		return errors.New("too many messages")
+	}
 	msg := tgbotapi.NewMessage(response.TargetChatId, response.TextMsg)
 	msg.ReplyToMessageID = response.MessageRepliedTo
+	msg.ParseMode = response.ParseMode
 	if response.InlineKeyboard != nil {
 		msg.ReplyMarkup = response.InlineKeyboard
 	} else if response.ReplyMarkup != nil {
 		msg.ReplyMarkup = response.ReplyMarkup
 	}
-	telegramBotAPI.Send(msg)
-
+	_, err := telegramBotAPI.Send(msg)
+	return err
 }
 
 func (telegramBotAPI *TelegramBotAPI) EditTextMessage(response TelegramResponse) {
@@ -58,6 +94,26 @@ func (telegramBotAPI *TelegramBotAPI) EditTextMessage(response TelegramResponse)
 	telegramBotAPI.Send(msg)
 }
 
+func (telegramBotAPI *TelegramBotAPI) SendPhoto(chatId int64, name string, photo []byte, caption string) error {
+	if !telegramBotAPI.AllowMessage(chatId) {
+		return errors.New("too many messages")
+	}
+	msg := tgbotapi.NewPhotoUpload(chatId, tgbotapi.FileBytes{Name: name, Bytes: photo})
+	msg.Caption = caption
+	_, err := telegramBotAPI.Send(msg)
+	return err
+}
+
+func (telegramBotAPI *TelegramBotAPI) SendDocument(chatId int64, name string, document []byte, caption string) error {
+	if !telegramBotAPI.AllowMessage(chatId) {
+		return errors.New("too many messages")
+	}
+	msg := tgbotapi.NewDocumentUpload(chatId, tgbotapi.FileBytes{Name: name, Bytes: document})
+	msg.Caption = caption
+	_, err := telegramBotAPI.Send(msg)
+	return err
+}
+
 func NewTelegramBotAPI(token string) (*TelegramBotAPI, error) {
 	bot, err := tgbotapi.NewBotAPI(token)
 	return &TelegramBotAPI{BotAPI: bot}, err
@@ -71,8 +127,15 @@ const (
 	TickTogo
 	UpdateTogo
 	RemoveTogo
+	CompleteTogo // reminder buttons
+	SnoozeTogo
+	PostponeTogo
+	SkipTogo
+	ChangeSetting // settings menu buttons
 )
 
+var SnoozeOptions = []int{5, 15, 60} // minutes
+
 type CallbackData struct {
 	Action  UserAction  `json:"A"`
 	ID      int64       `json:"ID,omitempty"`
@@ -95,6 +158,9 @@ func LoadCallbackData(jsonString string) (data CallbackData) {
 
 // ---------------------- Global Vars --------------------------------
 var env map[string]string
+var backupPolicy BackupPolicy
+var dispatcher *Dispatcher
+var scheduler *Scheduler
 
 // ---------------------- Telegram Response Related Functions ------------------------------
 func InlineKeyboardMenu(togos Togo.TogoList, action UserAction, allDays bool) (inlineKeyboard *tgbotapi.InlineKeyboardMarkup) {
@@ -123,6 +189,8 @@ func InlineKeyboardMenu(togos Togo.TogoList, action UserAction, allDays bool) (i
 		status := ""
 		if togos[i].Progress >= 100 {
 			status = "✅ "
+		} else if togos[i].Skipped {
+			status = "⏭ "
 		}
 		var togoTitle string = fmt.Sprint(status, togos[i].Title)
 		if len(togoTitle) >= MaximumInlineButtonTextLength {
@@ -137,6 +205,23 @@ func InlineKeyboardMenu(togos Togo.TogoList, action UserAction, allDays bool) (i
 	return
 }
 
+// ReminderKeyboard is the inline menu under each reminder: Done, Skip, Snooze for a few minutes and Move to tomorrow
+func ReminderKeyboard(togoId uint64, locale Togo.Locale) *tgbotapi.InlineKeyboardMarkup {
+	button := func(text string, action UserAction, data interface{}) tgbotapi.InlineKeyboardButton {
+		callback := (CallbackData{Action: action, ID: int64(togoId), Data: data, AllDays: true}).Json()
+		return tgbotapi.InlineKeyboardButton{Text: text, CallbackData: &callback}
+	}
+	snoozes := make([]tgbotapi.InlineKeyboardButton, len(SnoozeOptions))
+	for i, minutes := range SnoozeOptions {
+		snoozes[i] = button(locale.Text("snooze_button", minutes), SnoozeTogo, minutes)
+	}
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+		{button(locale.Text("done_button"), CompleteTogo, nil), button(locale.Text("skip_button"), SkipTogo, nil)},
+		snoozes,
+		{button(locale.Text("tomorrow"), PostponeTogo, nil)},
+	}}
+}
+
 func MainKeyboardMenu() *tgbotapi.ReplyKeyboardMarkup {
 	return &tgbotapi.ReplyKeyboardMarkup{ResizeKeyboard: true,
 		OneTimeKeyboard: false,
@@ -146,6 +231,34 @@ func MainKeyboardMenu() *tgbotapi.ReplyKeyboardMarkup {
 		}}
 }
 
+func StatisticsToString(stats Togo.Statistics, locale Togo.Locale) string {
+	text := locale.Text("stats_title", len(stats.Days), stats.Target) + "\n"
+	for _, day := range stats.Days {
+		mark := ""
+		if day.Total > 0 && day.Progress >= stats.Target {
+			mark = "✅ "
+		}
+		text += fmt.Sprintf("%s%s: %3.2f%% (%d / %d)", mark, locale.FormatDay(&day.Day), day.Progress, day.Completed, day.Total)
+		if day.Extra > 0 {
+			text += fmt.Sprintf("[+%d]", day.Extra)
+		}
+		text += "\n"
+	}
+	text += "\n" + locale.Text("streaks", stats.CurrentStreak, stats.BestStreak) + "\n"
+	text += "\n" + locale.Text("weekly_averages") + "\n"
+	for _, week := range stats.Weeks {
+		text += locale.Text("period_average", locale.FormatDay(&week.From)+" ~ "+locale.FormatDay(&week.To), week.Average, week.ActiveDays) + "\n"
+	}
+	text += "\n" + locale.Text("monthly_averages") + "\n"
+	for _, month := range stats.Months {
+		text += locale.Text("period_average", locale.FormatDay(&month.From)+" ~ "+locale.FormatDay(&month.To), month.Average, month.ActiveDays) + "\n"
+	}
+	if stats.ExtrasTotal > 0 {
+		text += "\n" + locale.Text("extras_done", stats.ExtrasDone, stats.ExtrasTotal, stats.ExtrasRatio()) + "\n"
+	}
+	return locale.Align(text)
+}
+
 // ---------------------- tgbotapi Related Functions ------------------------------
 func GetTgBotApiFunction(update *tgbotapi.Update) func(data string) error {
 	bot, err := tgbotapi.NewBotAPI(os.Getenv("TOKEN"))
@@ -190,311 +303,707 @@ func Log(update *tgbotapi.Update, values []string) {
 	sendMessage(r)
 }
 
-func (telegramBot *TelegramBotAPI) InformAdmin(news string) {
-	if admin_id, err := strconv.Atoi(env["ADMIN_ID"]); err == nil {
-		response := TelegramResponse{TextMsg: news, TargetChatId: int64(admin_id)} // default method is sendMessage
-		telegramBot.SendTextMessage(response)
-	} else {
-		log.Println("Cannot get admin id to inform him/her; news is: ", news)
+func (telegramBot *TelegramBotAPI) ImportDocument(ownerId int64, document *tgbotapi.Document, locale Togo.Locale) string {
+	var parse func(int64, []byte) (Togo.TogoList, []Togo.ImportIssue)
+	switch strings.ToLower(filepath.Ext(document.FileName)) {
+	case ".ics":
+		parse = Togo.ParseICalendar
+	case ".csv":
+		parse = Togo.ParseCSV
+	case ".json":
+		parse = Togo.ParseJSON
+	default:
+		return locale.Text("import_types")
+	}
+	if document.FileSize > MaximumImportFileSize {
+		return locale.Text("import_too_big")
+	}
+	url, err := telegramBot.GetFileDirectURL(document.FileID)
+	if err != nil {
+		return err.Error()
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return err.Error()
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaximumImportFileSize))
+	if err != nil {
+		return err.Error()
 	}
-}
-
-func (telegramBot *TelegramBotAPI) NotifyRightNowTogos() {
-	ticker := time.NewTicker(1 * time.Minute) // everyminute check togos
-	// if a ogo is set on a time equal to now, send telegram notification to that user
-	defer ticker.Stop()
-	notified_about_curroption := false
-	notified_about_load_problem := false
-	for range ticker.C {
-		// Put your code here that you want to run every one minute
-		if togos, err := Togo.LoadEverybodysToday(); togos != nil {
-			notified_about_load_problem = false
-			if err != nil {
-				if !notified_about_curroption {
-					notified_about_curroption = true
-
-					telegramBot.InformAdmin(fmt.Sprintln(err.Error(), "; this means the notification may encounter some problems on notifying some togos."))
-				}
-			} else {
-				notified_about_curroption = false
-			}
-			nextMinute := (Togo.Date{Time: Togo.Today().Add(time.Minute * time.Duration(1))}).ToLocal()
 
-			for _, togo := range togos {
-				if togo.Date.Get() == nextMinute.Get() {
-					// dates are equal if the string values are equal
-					response := TelegramResponse{TextMsg: togo.ToString(), TargetChatId: togo.OwnerId} // default method is sendMessage
-					telegramBot.SendTextMessage(response)
-				}
-			}
-		} else {
-			if !notified_about_load_problem {
-				notified_about_load_problem = true
-				telegramBot.InformAdmin(err.Error())
-			}
-		}
+	togos, rejected := parse(ownerId, data)
+	report, err := Togo.Import(ownerId, togos)
+	if err != nil {
+		return locale.Text("nothing_imported", err.Error())
 	}
+	report.Rejected = append(rejected, report.Rejected...)
+	return locale.Align(report.Localize(locale))
 }
 
-func main() {
-	var token string
-
-	env = nil
-
-	if envFile, err := godotenv.Read(".env"); err != nil {
-		panic(err)
-	} else {
-		env = envFile
-		token = env["TOKEN"]
+// Remind sends the reminder of the togo to its owner; the scheduler calls it a minute before the togo starts,
+// or after a restart, for the togos missed while the bot was down (late)
+func (telegramBot *TelegramBotAPI) Remind(togo Togo.Togo, late bool) error {
+	if settings, _ := Togo.LoadSettings(togo.OwnerId); !settings.Notifications {
+		return nil
+	}
+	locale := LocaleOf(togo.OwnerId, nil)
+	response := TelegramResponse{TextMsg: togo.Localize(locale), TargetChatId: togo.OwnerId, InlineKeyboard: ReminderKeyboard(togo.Id, locale)} // default method is sendMessage
+	if late {
+		response.TextMsg = fmt.Sprintln(locale.Text("late_reminder"), response.TextMsg)
 	}
+	if togo.Assignee != "" {
+		response.TextMsg = fmt.Sprint("🔔 @", togo.Assignee, "\n", response.TextMsg)
+	}
+	return telegramBot.TrySendTextMessage(response)
+}
 
-	bot, err := NewTelegramBotAPI(token)
-	if err != nil {
-		panic(err)
+// AnswerReminder applies the reminder button to the togo; the scheduler moves the reminder when the togo is updated
+func AnswerReminder(togo *Togo.Togo, callbackData CallbackData, locale Togo.Locale) string {
+	switch callbackData.Action {
+	case CompleteTogo:
+		togo.Progress = 100
+	case SnoozeTogo:
+		minutes, ok := callbackData.Data.(float64)
+		if !ok || minutes <= 0 {
+			return locale.Text("invalid_snooze")
+		}
+		start := togo.Date.Time
+		if now := time.Now(); start.Before(now) {
+			start = now.Truncate(time.Minute)
+		}
+		togo.Date = Togo.Date{Time: start.Add(time.Duration(minutes) * time.Minute)}.ToLocal()
+	case PostponeTogo:
+		togo.Date = Togo.Date{Time: togo.Date.AddDate(0, 0, 1)}
+	case SkipTogo:
+		// a skipped togo is kept, but it's not counted as open, nor reminded or rolled over
+		togo.Skipped = true
+	}
+	if err := togo.Update(togo.OwnerId); err != nil {
+		return err.Error()
 	}
+	answer := "moved_tomorrow"
+	switch callbackData.Action {
+	case CompleteTogo:
+		answer = "completed"
+	case SnoozeTogo:
+		answer = "snoozed"
+	case SkipTogo:
+		answer = "skipped"
+	}
+	return fmt.Sprint(locale.Text(answer), "\n", togo.Localize(locale))
+}
 
-	defer func() {
-		err := recover()
+func (telegramBot *TelegramBotAPI) HandleUpdate(update tgbotapi.Update) {
+	response := TelegramResponse{}
+
+	// ---------------------- Handling Casual Telegram text Messages ------------------------------
+	if update.Message != nil { // If we got a message
+		locale := LocaleOf(update.Message.Chat.ID, update.Message.From)
+		settings, err := Togo.LoadSettings(update.Message.Chat.ID)
 		if err != nil {
 			log.Println(err)
 		}
-	}()
-
-	// Create a new UpdateConfig struct with an offset of 0. Offsets are used
-	// to make sure Telegram knows we've handled previous values and we don't
-	// need them repeated.
-	updateConfig := tgbotapi.NewUpdate(0)
+		separator := settings.Separator.Line()
+		response.TextMsg = locale.Text("what")
+		response.ReplyMarkup = MainKeyboardMenu() // default keyboard
+		response.TargetChatId = update.Message.Chat.ID
+		response.MessageRepliedTo = update.Message.MessageID
+		userId := response.TargetChatId
+		if update.Message.From != nil {
+			userId = int64(update.Message.From.ID)
+		}
+		if update.Message.Document != nil {
+			if allowed, warn := telegramBot.CommandLimiter.Take(userId); !allowed {
+				if warn {
+					response.TextMsg = locale.Text("commands_limit")
+					telegramBot.SendTextMessage(response)
+				}
+				return
+			}
+			response.TextMsg = telegramBot.ImportDocument(update.Message.Chat.ID, update.Message.Document, locale)
+		}
+		terms := SplitArguments(update.Message.Text)
+		for j := range terms {
+			// free text (titles, descriptions) may contain / and @ too; only the commands are checked
+			if j == 0 || IsCommand(terms[j]) {
+				if command, addressed := telegramBot.CommandOf(terms[j]); addressed {
+					terms[j] = command
+				} else if j == 0 {
+					return // it's for another bot in the group
+				}
+			}
+			terms[j] = NormalizeTerm(terms[j])
+		}
 
-	// Tell Telegram we should wait up to 30 seconds on each request for an
-	// update. This way we can get information just as quickly as making many
-	// frequent requests without having to send nearly as many.
-	updateConfig.Timeout = 30
+		numOfTerms := len(terms)
 
-	// Start polling Telegram for updates.
-	updates, err := bot.GetUpdatesChan(updateConfig)
-	if err != nil {
-		panic(err)
-	}
-	// Let's go through each update that we're getting from Telegram.
+		var now Togo.Date = Togo.Today()
+		for i := 0; i < numOfTerms; i++ {
+			if IsCommand(terms[i]) {
+				// each command in the line counts, so that long chains of commands are limited too
+				if allowed, warn := telegramBot.CommandLimiter.Take(userId); !allowed {
+					if !warn {
+						return
+					}
+					response.TextMsg = locale.Text("commands_limit")
+					break
+				}
+			}
+			switch terms[i] {
+			case "+":
+				if numOfTerms > 1 {
+					var err error
+					togo := Togo.Extract(update.Message.Chat.ID, terms[i+1:], settings.DefaultWeight)
+					if togo.Id, err = togo.Save(); err == nil {
 
-	go bot.NotifyRightNowTogos() // run the scheduler that will check which togos are hapening right now, for each user
-	log.Println("configured.")
-	for update := range updates {
-		response := TelegramResponse{TextMsg: "What?"}
-
-		// ---------------------- Handling Casual Telegram text Messages ------------------------------
-		if update.Message != nil { // If we got a message
-			response.ReplyMarkup = MainKeyboardMenu() // default keyboard
-			response.TargetChatId = update.Message.Chat.ID
-			response.MessageRepliedTo = update.Message.MessageID
-			terms := SplitArguments(update.Message.Text)
-
-			numOfTerms := len(terms)
-
-			var now Togo.Date = Togo.Today()
-			for i := 0; i < numOfTerms; i++ {
-				switch terms[i] {
-				case "+":
-					if numOfTerms > 1 {
-						var err error
-						togo := Togo.Extract(update.Message.Chat.ID, terms[i+1:])
-						if togo.Id, err = togo.Save(); err == nil {
-
-							response.TextMsg = fmt.Sprint(now.Get(), ": DONE!")
-						} else {
-							response.TextMsg = err.Error()
-						}
+						response.TextMsg = locale.Text("created", locale.FormatDate(&now))
 					} else {
-						response.TextMsg = "You must provide at least one Parameters!"
-					}
-				case "#":
-					var results []string
-					just_undones := i+1 < numOfTerms && terms[i+1][0] == '-'
-					all_days := i+1 < numOfTerms && (terms[i+1] == "+a" || terms[i+1] == "-a")
-
-					togos, warning := Togo.Load(update.Message.Chat.ID, !all_days)
-					if togos == nil {
-						log.Println(warning)
-						response.TextMsg = warning.Error()
-						bot.SendTextMessage(response)
+						response.TextMsg = err.Error()
 					}
-					results = togos.ToString()
-					if len(results) > 0 {
-						for i := range results {
-							// newBug: result its not sorted by time
-							// possible fix: collect all togos in a day as single message
-							if togos[i].Progress >= 100 {
-								if just_undones {
-									continue
-								}
-								response.TextMsg = fmt.Sprint("✅ ", results[i])
-							} else {
-								response.TextMsg = results[i]
-							}
-							bot.SendTextMessage(response)
-						}
-						if warning == nil {
-							response.TextMsg = "✅!"
-						} else {
-							response.TextMsg = warning.Error()
-						}
+				} else {
+					response.TextMsg = locale.Text("need_parameters")
+				}
+			case "#":
+				// #  [range]  [-]  [sort  key]  [group  key]
+				within, options, last, err := ReadListingOptions(terms, i, locale.Calendar)
+				if i = last; err != nil {
+					response.TextMsg = err.Error()
+					break
+				}
+				options.JustUndones = options.JustUndones || !settings.ListCompleted
+
+				togos, warning := Togo.Load(update.Message.Chat.ID, within)
+				if togos == nil {
+					log.Println(warning)
+					response.TextMsg = warning.Error()
+					telegramBot.SendTextMessage(response)
+				}
+				// one message per group (day by default), sorted by time by default
+				sent := telegramBot.SendListing(response, togos, options, locale, separator)
+				shared, err := telegramBot.SendSharedTogos(response, within, options, locale, separator)
+				if err != nil && warning == nil {
+					warning = err
+				}
+				if sent > 0 || shared > 0 {
+					if warning == nil {
+						response.TextMsg = "✅!"
 					} else {
-						response.TextMsg = "Nothing!"
+						response.TextMsg = warning.Error()
 					}
+				} else {
+					response.TextMsg = locale.Text("nothing")
+				}
 
-				case "%":
-					var togos Togo.TogoList
-					var warning error
-					all_days := i+1 < numOfTerms && terms[i+1] == "a"
+			case "%":
+				// %  [range]  [group  key]
+				var togos Togo.TogoList
+				var warning error
+				within, options, last, err := ReadListingOptions(terms, i, locale.Calendar)
+				if i = last; err != nil {
+					response.TextMsg = err.Error()
+					break
+				}
 
-					togos, warning = Togo.Load(update.Message.Chat.ID, !all_days)
-					if togos == nil {
-						log.Println(warning.Error())
-						response.TextMsg = warning.Error()
-						bot.SendTextMessage(response)
-					} else {
-						progress, completedInPercent, completed, extra, total := togos.ProgressMade()
-						scope := "Today's"
-						if all_days {
-							scope = "Total"
-						}
-						response.TextMsg = fmt.Sprintf("%s Progress: %3.2f%% \n%3.2f%% Completed\nStatistics: %d / %d\n",
-							scope, progress, completedInPercent, completed, total)
-						if extra > 0 {
-							response.TextMsg = fmt.Sprintf("%s[+%d]\n", response.TextMsg, extra)
-						}
-						if warning != nil {
-							response.TextMsg = fmt.Sprintln(response.TextMsg, "- - - - - - - - - - - - - - - - - - - - - - \nwarning: ", warning.Error())
-						}
+				togos, warning = Togo.Load(update.Message.Chat.ID, within)
+				if togos == nil {
+					log.Println(warning.Error())
+					response.TextMsg = warning.Error()
+					telegramBot.SendTextMessage(response)
+				} else {
+					progress, completedInPercent, completed, extra, total := togos.Subtotal()
+					switch {
+					case within.IsAll():
+						response.TextMsg = locale.Text("progress_total", progress, completedInPercent, completed, total)
+					case within.Name == "today":
+						response.TextMsg = locale.Text("progress_today", progress, completedInPercent, completed, total)
+					default:
+						response.TextMsg = locale.Text("progress_range", locale.FormatRange(within), progress, completedInPercent, completed, total)
 					}
-				case "$":
-					//TODO: multiple seclect
-					var togos Togo.TogoList
-					var err error
-					// set or update a togo
-					if i+1 < numOfTerms {
-						togos, err = Togo.Load(update.Message.Chat.ID, false)
-						if togos != nil {
-							if resp, err := togos.Update(update.Message.Chat.ID, terms[i+1:]); err == nil {
-								response.TextMsg = resp
-							} else {
-								response.TextMsg = err.Error()
+					if extra > 0 {
+						response.TextMsg = fmt.Sprintf("%s[+%d]\n", response.TextMsg, extra)
+					}
+					if days := within.Days(); options.GroupBy != Togo.GroupByDay || (days > 1 && days <= MaximumSubtotalDays) {
+						response.TextMsg += GroupSubtotals(togos.Group(options, locale), locale) + "\n"
+					}
+					if IsGroup(update.Message.Chat) {
+						response.TextMsg += MembersProgressToString(togos, locale)
+					}
+					if warning != nil {
+						response.TextMsg = fmt.Sprintln(response.TextMsg, separator, "\n"+locale.Text("warning", warning.Error()))
+					}
+				}
+			case "/stats":
+				// /stats  [days]  [target]
+				days, target := DefaultStatisticsDays, DefaultStreakTarget
+				if i+1 < numOfTerms {
+					if _, err := fmt.Sscan(terms[i+1], &days); err == nil {
+						i++
+						if i+1 < numOfTerms {
+							if _, err := fmt.Sscan(terms[i+1], &target); err == nil {
+								i++
 							}
-						} else {
-							response.TextMsg = err.Error()
 						}
-
-					} else {
-						response.TextMsg = "You must provide the get identifier!"
 					}
-				// TODO: write Tick command
-				case "✅":
-					togos, err := Togo.Load(update.Message.Chat.ID, true)
+				}
+				if days <= 0 || days > MaximumStatisticsDays {
+					days = DefaultStatisticsDays
+				}
+				togos, warning := Togo.Load(update.Message.Chat.ID, Togo.AllDays())
+				if togos == nil {
+					log.Println(warning)
+					response.TextMsg = warning.Error()
+				} else {
+					response.TextMsg = StatisticsToString(togos.Statistics(days, target), locale)
+					if warning != nil {
+						response.TextMsg = fmt.Sprintln(response.TextMsg, separator, "\n"+locale.Text("warning", warning.Error()))
+					}
+				}
+			case "/chart":
+				togos, warning := Togo.Load(update.Message.Chat.ID, Togo.AllDays())
+				if togos == nil {
+					log.Println(warning)
+					response.TextMsg = warning.Error()
+					break
+				}
+				stats := togos.Statistics(NumberOfDaysInCharts, DefaultStreakTarget)
+				if chart, err := Togo.DailyChart(stats.Days, stats.Target); err == nil {
+					err = telegramBot.SendPhoto(response.TargetChatId, "daily.png", chart,
+						locale.Text("daily_chart", NumberOfDaysInCharts, stats.Target))
+					if err != nil {
+						log.Println(err)
+					}
+				}
+				today := Togo.Today().StartOfDay()
+				recent := togos.Between(today.AddDays(1-NumberOfDaysInCharts), today.AddDays(1))
+				if chart, err := Togo.WeightChart(recent.ProgressByWeight()); err == nil {
+					if err = telegramBot.SendPhoto(response.TargetChatId, "weights.png", chart,
+						locale.Text("weight_chart", NumberOfDaysInCharts)); err != nil {
+						log.Println(err)
+					}
+					response.TextMsg = "📊"
+				} else {
+					response.TextMsg = err.Error()
+				}
+				if warning != nil {
+					response.TextMsg = fmt.Sprintln(response.TextMsg, separator, "\n"+locale.Text("warning", warning.Error()))
+				}
+			case "/ics":
+				togos, warning := Togo.Load(update.Message.Chat.ID, Togo.AllDays())
+				if togos == nil {
+					log.Println(warning)
+					response.TextMsg = warning.Error()
+				} else if len(togos) == 0 {
+					response.TextMsg = locale.Text("nothing")
+				} else if err := telegramBot.SendDocument(response.TargetChatId, "togos.ics", togos.ICalendar(),
+					locale.Text("ics_caption", len(togos))); err != nil {
+					response.TextMsg = err.Error()
+				} else {
+					response.TextMsg = "📅"
+					if warning != nil {
+						response.TextMsg = fmt.Sprintln(response.TextMsg, separator, "\n"+locale.Text("warning", warning.Error()))
+					}
+				}
+			case "/export":
+				// /export  [json | csv]
+				format := "json"
+				if i+1 < numOfTerms && (terms[i+1] == "json" || terms[i+1] == "csv") {
+					format = terms[i+1]
+					i++
+				}
+				togos, warning := Togo.Load(update.Message.Chat.ID, Togo.AllDays())
+				if togos == nil {
+					log.Println(warning)
+					response.TextMsg = warning.Error()
+					break
+				}
+				var file []byte
+				var err error
+				if format == "csv" {
+					file, err = togos.CSV()
+				} else {
+					file, err = togos.JSON(update.Message.Chat.ID)
+				}
+				if err == nil {
+					err = telegramBot.SendDocument(response.TargetChatId, "togos."+format, file,
+						locale.Text("export_caption", len(togos)))
+				}
+				if err != nil {
+					response.TextMsg = err.Error()
+				} else {
+					response.TextMsg = "📦"
+					if warning != nil {
+						response.TextMsg = fmt.Sprintln(response.TextMsg, separator, "\n"+locale.Text("warning", warning.Error()))
+					}
+				}
+			case "$":
+				//TODO: multiple seclect
+				var togos Togo.TogoList
+				var err error
+				// set or update a togo
+				if i+1 < numOfTerms {
+					togos, err = Togo.Load(update.Message.Chat.ID, Togo.AllDays())
 					if togos != nil {
-						if len(togos) >= 1 {
-							response.TextMsg = "Here are your togos for today:"
-							response.InlineKeyboard = InlineKeyboardMenu(togos, TickTogo, false)
+						if resp, err := togos.Update(update.Message.Chat.ID, terms[i+1:], locale); err == nil {
+							response.TextMsg = resp
+						} else if resp, e := Togo.UpdateShared(update.Message.Chat.ID, terms[i+1:], locale); e == nil {
+							response.TextMsg = resp // it's shared with this chat
 						} else {
-							response.TextMsg = "No togos to tick!"
-						}
-						if err != nil {
-							response.TextMsg = fmt.Sprintln(response.TextMsg, "- - - - - - - - - - - - - - - - - - - - - - - -\nseems: ", err.Error())
+							response.TextMsg = err.Error()
 						}
 					} else {
 						response.TextMsg = err.Error()
 					}
-				case "❌":
-					var togos Togo.TogoList
-					var err error
-					all_days := i+1 < numOfTerms && terms[i+1] == "+a"
 
-					if togos, err = Togo.Load(update.Message.Chat.ID, !all_days); togos == nil {
-						log.Println(err)
+				} else {
+					response.TextMsg = locale.Text("need_id")
+				}
+			// TODO: write Tick command
+			case "✅":
+				togos, err := TickableTogos(update.Message.Chat.ID)
+				if togos != nil {
+					if len(togos) >= 1 {
+						response.TextMsg = locale.Text("tick_menu")
+						response.InlineKeyboard = InlineKeyboardMenu(togos, TickTogo, false)
+					} else {
+						response.TextMsg = locale.Text("nothing_to_tick")
+					}
+					if err != nil {
+						response.TextMsg = fmt.Sprintln(response.TextMsg, separator, "\n"+locale.Text("seems", err.Error()))
+					}
+				} else {
+					response.TextMsg = err.Error()
+				}
+			case "❌":
+				var togos Togo.TogoList
+				var err error
+				all_days := i+1 < numOfTerms && terms[i+1] == "+a"
+
+				if togos, err = Togo.Load(update.Message.Chat.ID, TodayOrAll(all_days)); togos == nil {
+					log.Println(err)
+					response.TextMsg = err.Error()
+					telegramBot.SendTextMessage(response)
+				} else {
+					response.TextMsg = locale.Text("remove_today")
+					if all_days {
+						response.TextMsg = locale.Text("remove_all")
+					}
+					if err != nil {
+						response.TextMsg = fmt.Sprintln(response.TextMsg, separator, "\n", err.Error())
+					}
+					response.InlineKeyboard = InlineKeyboardMenu(togos, RemoveTogo, all_days)
+				}
+			case "/db":
+				if IsAdmin(response.TargetChatId) {
+					// send a consistent snapshot instead of the file that the bot may be writing to
+					if path, err := backupPolicy.TakeBackup(); path == "" {
+						response.TextMsg = err.Error()
+					} else if err := telegramBot.SendBackup(response.TargetChatId, path); err != nil {
 						response.TextMsg = err.Error()
-						bot.SendTextMessage(response)
 					} else {
-						response.TextMsg = "Here are your Today's togos:"
-						if all_days {
-							response.TextMsg = "Here are your ALL togos:"
-						}
-						if err != nil {
-							response.TextMsg = fmt.Sprintln(response.TextMsg, "- - - - - - - - - - - - - - - - - - - - - - - -\n", err.Error())
-						}
-						response.InlineKeyboard = InlineKeyboardMenu(togos, RemoveTogo, all_days)
+						response.TextMsg = "Successfully sent db!"
 					}
-				case "/db":
-					if admin_id, err := strconv.Atoi(env["ADMIN_ID"]); err == nil && int64(admin_id) == response.TargetChatId {
-						msg := tgbotapi.NewDocumentUpload(int64(admin_id), "./togos.db")
-						if _, err := bot.Send(msg); err != nil {
-							response.TextMsg = err.Error()
-						} else {
-							response.TextMsg = "Successfully sent db!"
-						}
+				} else {
+					response.TextMsg = "get the fuck off my porch!"
+				}
+			case "/backups":
+				if IsAdmin(response.TargetChatId) {
+					if backups, err := backupPolicy.ListBackups(); err != nil {
+						response.TextMsg = err.Error()
+					} else if len(backups) == 0 {
+						response.TextMsg = "No backups yet!"
 					} else {
-						response.TextMsg = "get the fuck off my porch!"
+						response.TextMsg = fmt.Sprintf("%d backups in %s (keeping %d):\n%s", len(backups), backupPolicy.Directory,
+							backupPolicy.Keep, strings.Join(backups, "\n"))
 					}
-				case "/now":
-					response.TextMsg = now.Get()
-
+				} else {
+					response.TextMsg = "get the fuck off my porch!"
+				}
+			case "/restore":
+				// /restore  backup_name
+				if !IsAdmin(response.TargetChatId) {
+					response.TextMsg = "get the fuck off my porch!"
+				} else if i+1 >= numOfTerms {
+					response.TextMsg = "You must provide the backup name! (see /backups)"
+				} else if path, err := backupPolicy.BackupPath(terms[i+1]); err != nil {
+					response.TextMsg = err.Error()
+				} else if err := Togo.CheckSnapshot(path); err != nil {
+					response.TextMsg = err.Error()
+				} else if current, err := backupPolicy.Snapshot(); err != nil {
+					// not pruned before restoring, or the snapshot being restored might be removed as the oldest one
+					response.TextMsg = fmt.Sprintln("Cannot backup the current database before restoring: ", err.Error())
+				} else if err := Togo.Restore(path); err != nil {
+					response.TextMsg = err.Error()
+				} else {
+					response.TextMsg = fmt.Sprintf("Restored %s; the previous database is backed up as %s", terms[i+1], filepath.Base(current))
+					if scheduler != nil {
+						scheduler.Reload()
+					}
+					if err := backupPolicy.Prune(); err != nil {
+						log.Println("cannot remove old backups: ", err)
+					}
+				}
+			case "/botstats":
+				if IsAdmin(response.TargetChatId) {
+					response.TextMsg = BotStatisticsToString()
+				} else {
+					response.TextMsg = "get the fuck off my porch!"
+				}
+			case "/broadcast":
+				// /broadcast  message; the rest of the message is not parsed as commands
+				if !IsAdmin(response.TargetChatId) {
+					response.TextMsg = "get the fuck off my porch!"
+				} else if i+1 >= numOfTerms {
+					response.TextMsg = "You must provide the message!"
+				} else {
+					message := strings.Join(terms[i+1:], "  ")
+					telegramBot.Go(func(ctx context.Context) { telegramBot.Broadcast(ctx, response.TargetChatId, message) })
+					response.TextMsg = "Broadcasting ..."
+				}
+				i = numOfTerms
+			case "/ban", "/unban":
+				// /ban  telegram_id
+				if IsAdmin(response.TargetChatId) {
+					response.TextMsg = SetBanned(terms[i+1:], terms[i] == "/ban")
+					i++
+				} else {
+					response.TextMsg = "get the fuck off my porch!"
+				}
+			case "/share", "/unshare":
+				// the rest of the line belongs to the share command
+				if terms[i] == "/share" {
+					ownerName := "Someone"
+					if update.Message.From != nil {
+						ownerName = update.Message.From.FirstName
+					}
+					response.TextMsg = telegramBot.ShareCommand(response.TargetChatId, ownerName, terms[i+1:], locale)
+				} else {
+					response.TextMsg = UnshareCommand(response.TargetChatId, terms[i+1:], locale)
+				}
+				i = numOfTerms
+			case "/template":
+				// the rest of the line belongs to the template command
+				response.TextMsg = TemplateCommand(response.TargetChatId, terms[i+1:], settings.DefaultWeight, locale)
+				i = numOfTerms
+			case "/language":
+				// /language  [en | fa]
+				if i+1 < numOfTerms {
+					i++
+					locale = SetLanguage(response.TargetChatId, terms[i], locale)
 				}
+				response.TextMsg = fmt.Sprintln(locale.Text("language"), "\n", locale.Text("language_usage"))
+			case "/calendar":
+				// /calendar  [jalali | gregorian]
+				if i+1 < numOfTerms {
+					i++
+					locale = SetCalendar(response.TargetChatId, terms[i], locale)
+				}
+				response.TextMsg = fmt.Sprintln(locale.Text(string(locale.Calendar)), "\n", locale.Text("calendar_usage"))
+			case "/settings":
+				// /settings  [name  value]
+				if i+2 < numOfTerms {
+					if err := SetSetting(&settings, terms[i+1], terms[i+2]); err != nil {
+						response.TextMsg = err.Error()
+						i += 2
+						break
+					}
+					i += 2
+					separator = settings.Separator.Line()
+					locale.DateFormat = settings.DateFormat
+				}
+				response.TextMsg, response.InlineKeyboard = SettingsMenu(&settings, locale)
+			case "/rollover":
+				// /rollover  [on|off]
+				response.TextMsg = SetRollover(response.TargetChatId, terms[i+1:], locale)
+				if i+1 < numOfTerms && (terms[i+1] == "on" || terms[i+1] == "off") {
+					i++
+				}
+			case "/invite":
+				if IsAdmin(response.TargetChatId) {
+					response.TextMsg = telegramBot.NewInvite(response.TargetChatId, locale)
+				} else {
+					response.TextMsg = "get the fuck off my porch!"
+				}
+			case "/allow", "/disallow":
+				// /allow  chat_id
+				if IsAdmin(response.TargetChatId) {
+					response.TextMsg = SetAccess(response.TargetChatId, terms[i+1:], terms[i] == "/allow")
+					i++
+				} else {
+					response.TextMsg = "get the fuck off my porch!"
+				}
+			case "/now":
+				response.TextMsg = locale.FormatDate(&now)
 
 			}
-			bot.SendTextMessage(response)
 
-		} else if update.CallbackQuery != nil {
-			var togos Togo.TogoList
-			response.MessageBeingEditedId = update.CallbackQuery.Message.MessageID
-			response.TargetChatId = update.CallbackQuery.Message.Chat.ID
-			callbackData := LoadCallbackData(update.CallbackQuery.Data)
+		}
+		telegramBot.SendTextMessage(response)
 
+	} else if update.CallbackQuery != nil {
+		if allowed, _ := telegramBot.CommandLimiter.Take(int64(update.CallbackQuery.From.ID)); !allowed {
+			return
+		}
+		var togos Togo.TogoList
+		response.MessageBeingEditedId = update.CallbackQuery.Message.MessageID
+		response.TargetChatId = update.CallbackQuery.Message.Chat.ID
+		callbackData := LoadCallbackData(update.CallbackQuery.Data)
+		if callbackData.Action == ChangeSetting {
+			// the settings menu has nothing to do with togos
 			var err error
-			togos, err = Togo.Load(response.TargetChatId, !callbackData.AllDays)
-			if togos != nil {
+			if response.TextMsg, response.InlineKeyboard, err = NextSetting(response.TargetChatId, callbackData, update.CallbackQuery.From); err != nil {
+				response.TextMsg = err.Error()
+			}
+			telegramBot.EditTextMessage(response)
+			return
+		}
+		locale := LocaleOf(response.TargetChatId, update.CallbackQuery.From)
+
+		var err error
+		togos, err = Togo.Load(response.TargetChatId, TodayOrAll(callbackData.AllDays))
+		if togos != nil {
+			if err != nil {
+				log.Println(err)
+				response.TextMsg = err.Error()
+				telegramBot.SendTextMessage(response)
+			}
+			switch callbackData.Action {
+			case TickTogo:
+				togo, err := togos.Get(uint64(callbackData.ID))
+				if err != nil {
+					togo, _, err = Togo.LoadSharedTogo(response.TargetChatId, uint64(callbackData.ID))
+				}
 				if err != nil {
 					log.Println(err)
 					response.TextMsg = err.Error()
-					bot.SendTextMessage(response)
-				}
-				switch callbackData.Action {
-				case TickTogo:
-					togo, err := togos.Get(uint64(callbackData.ID))
-					if err != nil {
-						log.Println(err)
+					telegramBot.SendTextMessage(response)
+				} else {
+					if (*togo).Progress < 100 {
+						(*togo).Progress = 100
+					} else {
+						(*togo).Progress = 0
+					}
+					if err := togo.UpdateBy(response.TargetChatId); err != nil {
 						response.TextMsg = err.Error()
-						bot.SendTextMessage(response)
 					} else {
-						if (*togo).Progress < 100 {
-							(*togo).Progress = 100
-						} else {
-							(*togo).Progress = 0
-						}
-						(*togo).Update(response.TargetChatId)
-						response.InlineKeyboard = InlineKeyboardMenu(togos, TickTogo, false)
-						response.TextMsg = "✅ DONE! Now select the next togo you want to tick ..."
+						response.TextMsg = locale.Text("ticked")
 					}
-				case RemoveTogo:
-					togos, err := togos.Remove(response.TargetChatId, uint64(callbackData.ID))
-					if err == nil {
-						if len(togos) >= 1 {
-							response.TextMsg = "❌ DONE! Now select the next togo you want to REMOVE ..."
-							response.InlineKeyboard = InlineKeyboardMenu(togos, RemoveTogo, callbackData.AllDays)
-						} else {
-							response.TextMsg = "❌ DONE! All removed."
-						}
-
+					if tickables, _ := TickableTogos(response.TargetChatId); tickables != nil {
+						response.InlineKeyboard = InlineKeyboardMenu(tickables, TickTogo, false)
+					}
+				}
+			case RemoveTogo:
+				togos, err := togos.Remove(response.TargetChatId, uint64(callbackData.ID))
+				if err == nil {
+					if len(togos) >= 1 {
+						response.TextMsg = locale.Text("removed")
+						response.InlineKeyboard = InlineKeyboardMenu(togos, RemoveTogo, callbackData.AllDays)
 					} else {
-						log.Println(err)
-						response.TextMsg = err.Error()
-						bot.SendTextMessage(response)
+						response.TextMsg = locale.Text("all_removed")
 					}
+
+				} else {
+					log.Println(err)
+					response.TextMsg = err.Error()
+					telegramBot.SendTextMessage(response)
+				}
+			case CompleteTogo, SnoozeTogo, PostponeTogo, SkipTogo:
+				// the reminder buttons; the message is replaced without the buttons, so each reminder is answered once
+				togo, err := togos.Get(uint64(callbackData.ID))
+				if err != nil {
+					response.TextMsg = err.Error()
+				} else {
+					response.TextMsg = AnswerReminder(togo, callbackData, locale)
 				}
-			} else {
-				log.Println(err)
-				response.TextMsg = err.Error()
-				bot.SendTextMessage(response)
 			}
+		} else {
+			log.Println(err)
+			response.TextMsg = err.Error()
+			telegramBot.SendTextMessage(response)
+		}
 
-			bot.EditTextMessage(response)
+		telegramBot.EditTextMessage(response)
+	}
+}
+
+func main() {
+	var token string
+
+	env = nil
+
+	if envFile, err := godotenv.Read(".env"); err != nil {
+		panic(err)
+	} else {
+		env = envFile
+		token = env["TOKEN"]
+	}
+
+	bot, err := NewTelegramBotAPI(token)
+	if err != nil {
+		panic(err)
+	}
+	db, err := Togo.OpenDatabase(Togo.DATABASE_NAME)
+	if err != nil {
+		panic(err)
+	}
+	if err = Togo.Use(db); err != nil {
+		panic(err)
+	}
+	bot.CommandLimiter = LoadRateLimiter("RATE_COMMANDS", DefaultCommandsPerMinute, DefaultCommandsBurst)
+	bot.MessageLimiter = LoadRateLimiter("RATE_MESSAGES", DefaultMessagesPerMinute, DefaultMessagesBurst)
+
+	defer func() {
+		err := recover()
+		if err != nil {
+			log.Println(err)
 		}
+	}()
+
+	// SIGINT/SIGTERM cancel the context; then intake stops and everything running is drained before exit
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	bot.Context = ctx
+
+	// Start polling Telegram for updates, from the first one not processed before the last shutdown.
+	updates := bot.PollUpdates(ctx, LoadUpdateOffset())
+
+	// run the scheduler that will send the reminder of each togo right before it starts
+	scheduler = LoadScheduler(bot.Remind, func(err error) {
+		bot.InformAdmin(fmt.Sprintln(err.Error(), "; this means the notification may encounter some problems on notifying some togos."))
+	})
+	Togo.OnChange(scheduler.Changed)
+	bot.Go(scheduler.Run)
+	bot.Go(bot.RolloverNightly)
+	backupPolicy = LoadBackupPolicy()
+	bot.Go(func(ctx context.Context) { bot.BackupPeriodically(ctx, backupPolicy) })
+	accessPolicy = LoadAccessPolicy()
+	dispatcher = LoadDispatcher(func(update tgbotapi.Update) {
+		if accessPolicy.Authorize(bot, &update) {
+			bot.HandleUpdate(update)
+		}
+	})
+	bot.Go(ServeMetrics)
+	log.Println("configured.")
+	// Let's go through each update that we're getting from Telegram.
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case update, ok := <-updates:
+			if !ok || !dispatcher.Dispatch(ctx, update) {
+				running = false
+			}
+		}
+	}
+
+	log.Println("shutting down ...")
+	stop()
+	dispatcher.Close() // updates already queued are handled before exit
+	if !bot.Shutdown(ShutdownTimeout) {
+		log.Println("some background routines did not stop in time.")
+	}
+	if err := Togo.Close(); err != nil {
+		log.Println(err)
 	}
+	log.Println("bye.")
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+const LastRolloverKey = "last_rollover"
+
+// ---------------------- Nightly Rollover --------------------------------
+// RolloverNightly moves the unfinished togos of yesterday to today, right after midnight, for the users who turned it on;
+// the last day done is saved, so if the bot is down at midnight, it's done when it starts again.
+func (telegramBot *TelegramBotAPI) RolloverNightly(ctx context.Context) {
+	for {
+		today := Togo.Today().StartOfDay()
+		next := today.AddDays(1).Time
+		if err := telegramBot.RolloverOnce(today); err != nil {
+			log.Println("rollover: ", err)
+			next = time.Now().Add(SchedulerRetryInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// RolloverOnce moves the unfinished togos of the day before today, if it's not done yet, and tells their owners
+func (telegramBot *TelegramBotAPI) RolloverOnce(today Togo.Date) error {
+	day := today.Format(Togo.ActivityDayFormat)
+	if last, err := Togo.LoadState(LastRolloverKey); err != nil {
+		return err
+	} else if last == day {
+		return nil
+	}
+	moved, err := Togo.Rollover(today.AddDays(-1))
+	if err != nil {
+		return err
+	}
+	if err := Togo.SaveState(LastRolloverKey, day); err != nil {
+		return err
+	}
+	counts := make(map[int64]int)
+	for i := range moved {
+		counts[moved[i].OwnerId]++
+	}
+	for ownerId, count := range counts {
+		if settings, _ := Togo.LoadSettings(ownerId); !settings.Notifications {
+			continue
+		}
+		telegramBot.SendTextMessage(TelegramResponse{TargetChatId: ownerId, TextMsg: LocaleOf(ownerId, nil).Text("rolled_over", count)})
+	}
+	return nil
+}
+
+// SetRollover handles /rollover  [on|off]
+func SetRollover(chatId int64, terms []string, locale Togo.Locale) string {
+	if len(terms) > 0 && (terms[0] == "on" || terms[0] == "off") {
+		if err := Togo.SetRollover(chatId, terms[0] == "on"); err != nil {
+			return err.Error()
+		}
+	}
+	enabled, err := Togo.IsRolloverEnabled(chatId)
+	if err != nil {
+		return err.Error()
+	}
+	if enabled {
+		return locale.Text("rollover_on")
+	}
+	return locale.Text("rollover_off")
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+const (
+	ReminderAhead          = time.Minute    // reminders are sent this long before the togo starts
+	SchedulerHorizon       = 24 * time.Hour // togos are loaded into the queue this far ahead
+	SchedulerRetryInterval = time.Minute
+	DefaultReminderGrace   = 60          // minutes
+	ReminderLateAfter      = time.Minute // reminders sent later than this are marked as late
+)
+
+// ---------------------- Reminder Queue --------------------------------
+type reminder struct {
+	togo  Togo.Togo
+	at    time.Time
+	due   time.Time // when it's sent; after at, if sending it has failed before
+	index int       // position in the queue
+}
+
+// reminderQueue is a min-heap of reminders, ordered by the time they're due
+type reminderQueue []*reminder
+
+func (queue reminderQueue) Len() int           { return len(queue) }
+func (queue reminderQueue) Less(i, j int) bool { return queue[i].due.Before(queue[j].due) }
+func (queue reminderQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+	queue[i].index = i
+	queue[j].index = j
+}
+
+func (queue *reminderQueue) Push(item interface{}) {
+	next := item.(*reminder)
+	next.index = len(*queue)
+	*queue = append(*queue, next)
+}
+
+func (queue *reminderQueue) Pop() interface{} {
+	old := *queue
+	last := old[len(old)-1]
+	old[len(old)-1] = nil
+	*queue = old[:len(old)-1]
+	return last
+}
+
+// ---------------------- Scheduler --------------------------------
+// Scheduler keeps the upcoming reminders in a time ordered queue and sleeps until the next one is due.
+// Togos are loaded SchedulerHorizon ahead, and every change of a togo after that is applied by Changed.
+// At start, the reminders missed in the last grace period (while the bot was down) are loaded too, and sent as late;
+// each reminder is recorded in the database before it's sent, so none of them is sent twice; the record is removed
+// if sending fails, and the reminder is tried again every SchedulerRetryInterval, until the grace period is over.
+type Scheduler struct {
+	mu          sync.Mutex
+	queue       reminderQueue
+	byTogo      map[uint64]*reminder
+	loadedUntil time.Time // the reminders before this time are in the queue
+	reloadAt    time.Time
+	lastError   string
+	grace       time.Duration
+	wake        chan struct{}
+	remind      func(togo Togo.Togo, late bool) error
+	report      func(err error)
+}
+
+func NewScheduler(grace time.Duration, remind func(togo Togo.Togo, late bool) error, report func(err error)) *Scheduler {
+	return &Scheduler{byTogo: make(map[uint64]*reminder), grace: grace, wake: make(chan struct{}, 1), remind: remind, report: report}
+}
+
+// LoadScheduler reads REMINDER_GRACE (in minutes) from the .env file
+func LoadScheduler(remind func(togo Togo.Togo, late bool) error, report func(err error)) *Scheduler {
+	grace := DefaultReminderGrace * time.Minute
+	if minutes, err := strconv.Atoi(env["REMINDER_GRACE"]); err == nil && minutes >= 0 {
+		grace = time.Duration(minutes) * time.Minute
+	}
+	return NewScheduler(grace, remind, report)
+}
+
+// Schedule adds the reminder of the togo, or moves it if it's already scheduled; reminders in the past
+// are dropped, and the ones after the loaded window are left to be loaded later.
+func (scheduler *Scheduler) Schedule(togo *Togo.Togo) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.unschedule(togo.Id)
+	scheduler.schedule(togo, time.Now().Truncate(time.Minute))
+}
+
+func (scheduler *Scheduler) Unschedule(togoId uint64) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.unschedule(togoId)
+}
+
+// Changed is the Togo change listener
+func (scheduler *Scheduler) Changed(togo *Togo.Togo, removed bool) {
+	if removed {
+		scheduler.Unschedule(togo.Id)
+	} else {
+		scheduler.Schedule(togo)
+	}
+}
+
+// Reload drops the queued reminders and makes Run load them again from the database, from now on;
+// it's needed when the database is replaced, e.g. by /restore
+func (scheduler *Scheduler) Reload() {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.queue = scheduler.queue[:0]
+	scheduler.byTogo = make(map[uint64]*reminder)
+	scheduler.loadedUntil = time.Now().Truncate(time.Minute) // not zero, so the missed reminders are not loaded again
+	scheduler.reloadAt = time.Time{}
+	select {
+	case scheduler.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len is the number of scheduled reminders
+func (scheduler *Scheduler) Len() int {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	return len(scheduler.queue)
+}
+
+// schedule and unschedule must be called with mu locked; reminders before since are dropped
+func (scheduler *Scheduler) schedule(togo *Togo.Togo, since time.Time) {
+	at := togo.Date.Add(-ReminderAhead)
+	if togo.Skipped || at.Before(since) || !at.Before(scheduler.loadedUntil) {
+		return
+	}
+	scheduler.push(&reminder{togo: *togo, at: at, due: at})
+}
+
+// push adds the reminder to the queue, and wakes Run up if it's the next one; must be called with mu locked
+func (scheduler *Scheduler) push(next *reminder) {
+	heap.Push(&scheduler.queue, next)
+	scheduler.byTogo[next.togo.Id] = next
+	if next.index == 0 {
+		select {
+		case scheduler.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (scheduler *Scheduler) unschedule(togoId uint64) {
+	if old, ok := scheduler.byTogo[togoId]; ok {
+		heap.Remove(&scheduler.queue, old.index)
+		delete(scheduler.byTogo, togoId)
+	}
+}
+
+// load adds the reminders due before until to the queue, and the missed ones at start; must be called with mu locked
+func (scheduler *Scheduler) load(now time.Time, until time.Time) error {
+	from := scheduler.loadedUntil
+	if from.IsZero() {
+		from = now.Add(-scheduler.grace).Truncate(time.Minute)
+	} else if from.Before(now) {
+		from = now.Truncate(time.Minute)
+	}
+	if err := Togo.ForgetRemindersBefore(now.Add(-scheduler.grace - ReminderLateAfter)); err != nil {
+		log.Println("scheduler: ", err)
+	}
+	togos, err := Togo.LoadEverybodysBetween(from.Add(ReminderAhead), until.Add(ReminderAhead))
+	if togos == nil {
+		return err
+	}
+	scheduler.loadedUntil = until
+	for i := range togos {
+		scheduler.unschedule(togos[i].Id)
+		scheduler.schedule(&togos[i], from)
+	}
+	return err // just a warning about the currupted rows
+}
+
+// Run sends the reminders when they're due, until ctx is canceled
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	for {
+		scheduler.mu.Lock()
+		now := time.Now()
+		if !now.Before(scheduler.reloadAt) {
+			err := scheduler.load(now, now.Add(SchedulerHorizon))
+			if scheduler.loadedUntil.After(now) {
+				scheduler.reloadAt = scheduler.loadedUntil.Add(-SchedulerHorizon / 2)
+			} else {
+				scheduler.reloadAt = now.Add(SchedulerRetryInterval)
+			}
+			scheduler.reportOnce(err)
+		}
+		due := make([]*reminder, 0)
+		for len(scheduler.queue) > 0 && !scheduler.queue[0].due.After(now) {
+			next := heap.Pop(&scheduler.queue).(*reminder)
+			delete(scheduler.byTogo, next.togo.Id)
+			due = append(due, next)
+		}
+		wakeAt := scheduler.reloadAt
+		if len(scheduler.queue) > 0 && scheduler.queue[0].due.Before(wakeAt) {
+			wakeAt = scheduler.queue[0].due
+		}
+		scheduler.mu.Unlock()
+
+		for _, next := range due {
+			if claimed, err := Togo.ClaimReminder(next.togo.Id, next.at); err != nil {
+				log.Println("scheduler: ", err) // better to send it twice, than not to send it at all
+			} else if !claimed {
+				continue
+			}
+			if err := scheduler.remind(next.togo, now.Sub(next.at) > ReminderLateAfter); err != nil {
+				log.Println("scheduler: cannot send the reminder: ", err)
+				scheduler.retry(next, now)
+			}
+		}
+		timer := time.NewTimer(time.Until(wakeAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-scheduler.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// retry releases the claim of the reminder that's not sent, and schedules it again, unless it's too late or the togo is rescheduled meanwhile
+func (scheduler *Scheduler) retry(failed *reminder, now time.Time) {
+	if err := Togo.ReleaseReminder(failed.togo.Id, failed.at); err != nil {
+		log.Println("scheduler: ", err)
+	}
+	failed.due = now.Add(SchedulerRetryInterval)
+	if failed.due.Sub(failed.at) > scheduler.grace {
+		return
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if _, rescheduled := scheduler.byTogo[failed.togo.Id]; !rescheduled {
+		scheduler.push(failed)
+	}
+}
+
+// reportOnce reports each error once, until it's gone or changed; must be called with mu locked
+func (scheduler *Scheduler) reportOnce(err error) {
+	if err == nil {
+		scheduler.lastError = ""
+	} else if err.Error() != scheduler.lastError {
+		scheduler.lastError = err.Error()
+		log.Println("scheduler: ", err)
+		if scheduler.report != nil {
+			scheduler.report(err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+// ---------------------- Settings Menu --------------------------------
+// SettingLabel is the text of the setting's button: its name and current value, in the language of the locale
+func SettingLabel(settings *Togo.Settings, name string, locale Togo.Locale) string {
+	value := settings.Value(name)
+	if value == "true" || value == "false" {
+		value = locale.Text(value)
+	}
+	return locale.Text("setting_"+name, value)
+}
+
+// SettingsMenu is the message of /settings; each button of its inline keyboard changes its option to the next one
+func SettingsMenu(settings *Togo.Settings, locale Togo.Locale) (string, *tgbotapi.InlineKeyboardMarkup) {
+	var menu tgbotapi.InlineKeyboardMarkup
+	menu.InlineKeyboard = make([][]tgbotapi.InlineKeyboardButton, len(Togo.SettingNames))
+	for i, name := range Togo.SettingNames {
+		data := (CallbackData{Action: ChangeSetting, Data: name}).Json()
+		menu.InlineKeyboard[i] = []tgbotapi.InlineKeyboardButton{{Text: SettingLabel(settings, name, locale), CallbackData: &data}}
+	}
+	return locale.Text("settings"), &menu
+}
+
+// SetSetting handles /settings  name  value
+func SetSetting(settings *Togo.Settings, name string, value string) error {
+	if err := settings.Set(strings.ToLower(name), strings.ToLower(value)); err != nil {
+		return err
+	}
+	return settings.Save()
+}
+
+// NextSetting handles the buttons of the settings menu; it returns the updated menu
+func NextSetting(chatId int64, callbackData CallbackData, user *tgbotapi.User) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	name, ok := callbackData.Data.(string)
+	if !ok {
+		return "", nil, errors.New("invalid setting")
+	}
+	settings, err := Togo.LoadSettings(chatId)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := settings.Next(name); err != nil {
+		return "", nil, err
+	}
+	if err := settings.Save(); err != nil {
+		return "", nil, err
+	}
+	text, menu := SettingsMenu(&settings, LocaleOf(chatId, user))
+	return text, menu, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"html"
+
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+// ---------------------- Share Commands --------------------------------
+// ShareCommand handles /share  [@username | id]  [view | edit]  [togo_id ...]; without any terms it lists the shares
+func (telegramBot *TelegramBotAPI) ShareCommand(ownerId int64, ownerName string, terms []string, locale Togo.Locale) string {
+	if len(terms) == 0 {
+		return SharesToString(ownerId, locale)
+	}
+	grantee := terms[0]
+	granteeId, err := Togo.FindUser(grantee)
+	if err != nil {
+		return err.Error()
+	}
+	terms = terms[1:]
+	canEdit := false
+	if len(terms) > 0 && (terms[0] == "view" || terms[0] == "edit") {
+		canEdit = terms[0] == "edit"
+		terms = terms[1:]
+	}
+	togoIds, err := TogoIds(terms)
+	if err != nil {
+		return err.Error()
+	}
+	if err := Togo.ShareTogos(ownerId, granteeId, togoIds, canEdit); err != nil {
+		return err.Error()
+	}
+	access := "share_access_view"
+	if canEdit {
+		access = "share_access_edit"
+	}
+	// the grantee is told in their own language
+	theirs := LocaleOf(granteeId, nil)
+	their, your := theirs.Text("share_scope_all_their"), locale.Text("share_scope_all_your")
+	if len(togoIds) > 0 {
+		their, your = theirs.Text("share_scope_some_their", len(togoIds)), locale.Text("share_scope_some_your", len(togoIds))
+	}
+	telegramBot.SendTextMessage(TelegramResponse{TargetChatId: granteeId,
+		TextMsg: theirs.Align(theirs.Text("shared_with_you", ownerName, their, theirs.Text(access)))})
+	return locale.Align(locale.Text("shared", grantee, locale.Text(access), your))
+}
+
+// UnshareCommand handles /unshare  [@username | id]  [togo_id ...]; without togo ids, all the shares with the user are revoked
+func UnshareCommand(ownerId int64, terms []string, locale Togo.Locale) string {
+	if len(terms) == 0 {
+		return locale.Text("need_user")
+	}
+	granteeId, err := Togo.FindUser(terms[0])
+	if err != nil {
+		return err.Error()
+	}
+	togoIds, err := TogoIds(terms[1:])
+	if err != nil {
+		return err.Error()
+	}
+	revoked, err := Togo.UnshareTogos(ownerId, granteeId, togoIds)
+	if err != nil {
+		return err.Error()
+	}
+	return locale.Text("unshared", revoked)
+}
+
+func TogoIds(terms []string) ([]uint64, error) {
+	ids := make([]uint64, len(terms))
+	for i := range terms {
+		if _, err := fmt.Sscan(terms[i], &ids[i]); err != nil || ids[i] == 0 {
+			return nil, fmt.Errorf("%s is not a togo id", terms[i])
+		}
+	}
+	return ids, nil
+}
+
+func SharesToString(userId int64, locale Togo.Locale) string {
+	given, received, err := Togo.LoadShares(userId)
+	if err != nil {
+		return err.Error()
+	}
+	if len(given) == 0 && len(received) == 0 {
+		return locale.Text("nothing_shared")
+	}
+	text := locale.Text("shares_given") + "\n"
+	for i := range given {
+		text += given[i].Localize(locale) + "\n"
+	}
+	text += "\n" + locale.Text("shares_received") + "\n"
+	for i := range received {
+		text += received[i].Localize(locale) + "\n"
+	}
+	return locale.Align(text)
+}
+
+// SendSharedTogos sends the togos shared with the chat, as one HTML message per owner and group; it returns the number of togos sent
+func (telegramBot *TelegramBotAPI) SendSharedTogos(response TelegramResponse, within Togo.Range, options Togo.ListingOptions, locale Togo.Locale, separator string) (int, error) {
+	lists, err := Togo.LoadSharedWith(response.TargetChatId, within)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, list := range lists {
+		viewOnly := func(togo *Togo.Togo) string {
+			if !list.Editable[togo.Id] {
+				return locale.Text("view_only")
+			}
+			return ""
+		}
+		header := locale.Align(html.EscapeString(locale.Text("shared_by", list.OwnerName)))
+		for _, group := range list.Togos.ListingHTML(options, locale, separator, viewOnly) {
+			telegramBot.SendHTML(response, header+"\n"+group)
+		}
+		if options.JustUndones {
+			sent += len(Undones(list.Togos))
+		} else {
+			sent += len(list.Togos)
+		}
+	}
+	return sent, nil
+}
+
+// TickableTogos is today's togos of the chat, and the ones shared with it for editing
+func TickableTogos(chatId int64) (Togo.TogoList, error) {
+	togos, err := Togo.Load(chatId, Togo.TodayRange())
+	if togos == nil {
+		return nil, err
+	}
+	if lists, e := Togo.LoadSharedWith(chatId, Togo.TodayRange()); e == nil {
+		for _, list := range lists {
+			for i := range list.Togos {
+				if list.Editable[list.Togos[i].Id] {
+					togos = togos.Add(&list.Togos[i])
+				}
+			}
+		}
+	} else if err == nil {
+		err = e
+	}
+	return togos, err
+}
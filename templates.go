@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+// ---------------------- Template Commands --------------------------------
+// TemplateCommand handles /template  [list | save | edit | delete | use]  ...; see README for the syntax of each one
+func TemplateCommand(ownerId int64, terms []string, defaultWeight uint16, locale Togo.Locale) string {
+	if len(terms) == 0 || terms[0] == "list" {
+		templates, err := Togo.LoadTemplates(ownerId, "")
+		if err != nil {
+			return err.Error()
+		} else if len(templates) == 0 {
+			return locale.Text("no_templates")
+		}
+		results := make([]string, len(templates))
+		for i := range templates {
+			results[i] = templates[i].Localize(locale)
+		}
+		return strings.Join(results, "\n\n")
+	}
+	if len(terms) < 2 {
+		return locale.Text("need_template_name")
+	}
+	name := terms[1]
+	switch terms[0] {
+	case "save", "edit":
+		items, err := Togo.ParseTemplateItems(terms[2:], defaultWeight)
+		if err != nil {
+			return err.Error()
+		}
+		template := Togo.Template{OwnerId: ownerId, Name: name, Items: items}
+		if err := Togo.SaveTemplate(&template, terms[0] == "edit"); err != nil {
+			return err.Error()
+		}
+		return locale.Text("template_saved", template.Localize(locale))
+	case "delete":
+		if err := Togo.RemoveTemplate(ownerId, name); err != nil {
+			return err.Error()
+		}
+		return locale.Text("template_deleted", name)
+	case "use":
+		template, err := Togo.LoadTemplate(ownerId, name)
+		if err != nil {
+			return err.Error()
+		}
+		daysFromNow := 0
+		if len(terms) > 2 {
+			if daysFromNow, err = DaysFromToday(terms[2]); err != nil {
+				return err.Error()
+			}
+		}
+		togos, err := template.Instantiate(daysFromNow)
+		if err != nil {
+			return locale.Text("template_partly_used", len(togos), err.Error())
+		}
+		return locale.Text("template_used", len(togos), template.Name)
+	}
+	return locale.Text("unknown_template_command", terms[0])
+}
+
+// DaysFromToday reads a day as the number of days from today, or as a jalali or gregorian date (see Togo.ParseDay)
+func DaysFromToday(day string) (int, error) {
+	if days, err := strconv.Atoi(day); err == nil {
+		return days, nil
+	}
+	today := Togo.Today().StartOfDay()
+	date, err := Togo.ParseDay(day, today.Location())
+	if err != nil {
+		return 0, errors.New("day must be a number of days from today, or a date like 1403/07/28 or 2024-10-19")
+	}
+	return int(math.Round(date.Sub(today.Time).Hours() / 24)), nil
+}
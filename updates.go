@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	Togo "github.com/pya-h/ToGo4BotPlus/Togo"
+)
+
+const (
+	LastUpdateIdKey      = "last_update_id"
+	UpdatesPollTimeout   = 30 // seconds
+	UpdatesRetryInterval = 3 * time.Second
+	ShutdownTimeout      = 30 * time.Second
+)
+
+// ---------------------- Update Offset --------------------------------
+// LoadUpdateOffset returns the id of the first update that is not processed yet
+func LoadUpdateOffset() int {
+	value, err := Togo.LoadState(LastUpdateIdKey)
+	if err != nil {
+		log.Println("cannot load the last update id: ", err)
+	}
+	if id, err := strconv.Atoi(value); err == nil {
+		return id + 1
+	}
+	return 0
+}
+
+func SaveUpdateOffset(lastUpdateId int) {
+	if err := Togo.SaveState(LastUpdateIdKey, strconv.Itoa(lastUpdateId)); err != nil {
+		log.Println("cannot save the last update id: ", err)
+	}
+}
+
+// ---------------------- Update Polling --------------------------------
+// PollUpdates is like tgbotapi GetUpdatesChan, but stops when ctx is canceled. Telegram forgets the updates of a batch
+// only when the next batch is requested, which happens after all of them are handed over; so the updates that are
+// not handed over before shutdown, are sent again after the restart.
+func (telegramBot *TelegramBotAPI) PollUpdates(ctx context.Context, offset int) <-chan tgbotapi.Update {
+	updates := make(chan tgbotapi.Update)
+	go func() {
+		defer close(updates)
+		config := tgbotapi.NewUpdate(offset)
+		config.Timeout = UpdatesPollTimeout
+		for ctx.Err() == nil {
+			batch, err := telegramBot.GetUpdates(config)
+			if err != nil {
+				log.Println(err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(UpdatesRetryInterval):
+				}
+				continue
+			}
+			for _, update := range batch {
+				select {
+				case updates <- update:
+					if update.UpdateID >= config.Offset {
+						config.Offset = update.UpdateID + 1
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return updates
+}
+
+// ---------------------- Background Routines --------------------------------
+// Go runs the routine in the background; Shutdown waits for all of them to return
+func (telegramBot *TelegramBotAPI) Go(routine func(ctx context.Context)) {
+	telegramBot.routines.Add(1)
+	go func() {
+		defer telegramBot.routines.Done()
+		routine(telegramBot.Context)
+	}()
+}
+
+// Shutdown waits for the background routines (which must stop when the context is canceled), at most for the timeout
+func (telegramBot *TelegramBotAPI) Shutdown(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		telegramBot.routines.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}